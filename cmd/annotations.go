@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Annotation is a note pinned to a point of a model, shown as a numbered hotspot that opens a
+// popup. Position and Normal are in model coordinates as <model-viewer> expects, e.g. "0.1m 0 -0.2m".
+type Annotation struct {
+	Position string `yaml:"Position"`
+	Normal   string `yaml:"Normal,omitempty"`
+	Title    string `yaml:"Title"`
+	Body     string `yaml:"Body,omitempty"`
+	Media    string `yaml:"Media,omitempty"` // image, video or audio shown in the popup, or a link
+}
+
+// mediaKinds maps file extensions to the element an annotation's media is shown with
+var mediaKinds = map[string]string{
+	".png": "image", ".jpg": "image", ".jpeg": "image", ".gif": "image", ".webp": "image", ".svg": "image",
+	".mp4": "video", ".webm": "video", ".mov": "video",
+	".mp3": "audio", ".ogg": "audio", ".wav": "audio", ".m4a": "audio",
+}
+
+// MediaKind returns whether the media of the annotation is an image, video or audio file,
+// or a link to anything else
+func (a Annotation) MediaKind() string {
+	u, err := url.Parse(a.Media)
+	if err != nil {
+		return "link"
+	}
+	if kind, ok := mediaKinds[strings.ToLower(path.Ext(u.Path))]; ok {
+		return kind
+	}
+	return "link"
+}
+
+// validateAnnotation checks a single annotation, returning every invalid field
+func validateAnnotation(a Annotation) []fieldError {
+	var errs []fieldError
+	if strings.TrimSpace(a.Position) == "" {
+		errs = append(errs, fieldError{Field: "Position", Message: "is required"})
+	} else if !isVector(a.Position) {
+		errs = append(errs, fieldError{Field: "Position", Message: fmt.Sprintf("%q must be three coordinates like \"0.1m 0 -0.2m\"", a.Position)})
+	}
+	if a.Normal != "" && !isVector(a.Normal) {
+		errs = append(errs, fieldError{Field: "Normal", Message: fmt.Sprintf("%q must be three coordinates like \"0 1 0\"", a.Normal)})
+	}
+	if strings.TrimSpace(a.Title) == "" {
+		errs = append(errs, fieldError{Field: "Title", Message: "is required"})
+	}
+
+	if a.Media != "" {
+		if strings.HasPrefix(a.Media, "/") {
+			if msg, missing := checkStaticPath(a.Media); msg != "" {
+				errs = append(errs, fieldError{Field: "Media", Message: msg, Missing: missing})
+			}
+		} else if u, err := url.Parse(a.Media); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fieldError{Field: "Media", Message: fmt.Sprintf("%q must be a /static/ path or an http(s) URL", a.Media)})
+		}
+	}
+	return errs
+}
+
+// isVector reports whether s holds three numbers, each optionally suffixed with a length unit
+func isVector(s string) bool {
+	parts := strings.Fields(s)
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		for _, suffix := range []string{"mm", "cm", "m"} {
+			if trimmed, ok := strings.CutSuffix(part, suffix); ok {
+				part = trimmed
+				break
+			}
+		}
+		if _, err := strconv.ParseFloat(part, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateAnnotation(t *testing.T) {
+	useTestUIFiles(t)
+
+	valid := []Annotation{
+		{Position: "0.1m 0.2m -0.05m", Normal: "0 1 0", Title: "Crack", Body: "Damage from 1920", Media: "/static/models/obj1/object1.webp"},
+		{Position: "1 2 3", Title: "Mark", Media: "https://example.com/mark.mp4"},
+	}
+	for _, a := range valid {
+		if errs := validateAnnotation(a); len(errs) != 0 {
+			t.Errorf("Expected no errors for %+v, got %v", a, errs)
+		}
+	}
+
+	var fields []string
+	for _, fe := range validateAnnotation(Annotation{Position: "1 2", Normal: "up", Media: "mark.png"}) {
+		fields = append(fields, fe.Field)
+	}
+	if expected := []string{"Position", "Normal", "Title", "Media"}; !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Expected errors for %v, got %v", expected, fields)
+	}
+}
+
+func TestAnnotationMediaKind(t *testing.T) {
+	tests := map[string]string{
+		"/static/img/crack.JPG":              "image",
+		"https://example.com/clip.mp4?t=10":  "video",
+		"/static/audio/story.mp3":            "audio",
+		"https://example.com/about-the-dust": "link",
+	}
+	for media, expected := range tests {
+		if kind := (Annotation{Media: media}).MediaKind(); kind != expected {
+			t.Errorf("MediaKind(%q) = %s, expected %s", media, kind, expected)
+		}
+	}
+}
+
+func TestRenderAnnotations(t *testing.T) {
+	page := testPage("Model 1")
+	page.Annotations = []Annotation{
+		{Position: "0 1m 0", Normal: "0 1 0", Title: "Top", Body: "The top", Media: "/static/img/top.png"},
+		{Position: "1 0 0", Title: "Side", Media: "https://example.com/side"},
+	}
+	config := Config{Pages: map[string]PageConfig{"page1": page, "page2": testPage("Model 2")}}
+
+	for _, layout := range []string{"card", "plain"} {
+		var buf strings.Builder
+		if err := renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page1", layout); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		html := buf.String()
+		for _, expected := range []string{
+			`slot="hotspot-annotation-1" class="annotation" data-position="0 1m 0" data-normal="0 1 0"`,
+			`slot="hotspot-annotation-2" class="annotation" data-position="1 0 0"`,
+			`<img class="annotation-media" loading="lazy" src="/static/img/top.png" alt="Top">`,
+			`<a class="annotation-media" href="https://example.com/side" target="_blank">`,
+			`id="show-annotations"`,
+		} {
+			if !strings.Contains(html, expected) {
+				t.Errorf("Expected %s layout to contain %s", layout, expected)
+			}
+		}
+
+		buf.Reset()
+		renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page2", layout)
+		if strings.Contains(buf.String(), "show-annotations") {
+			t.Errorf("Expected %s layout to have no annotation toggle without annotations", layout)
+		}
+	}
+}
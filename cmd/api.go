@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// apiPrefixes lists the paths the JSON API is served under; /api always points at the latest version
+var apiPrefixes = []string{"/api/v1", "/api"}
+
+// apiError is the JSON body of an API error response
+type apiError struct {
+	Error  string       `json:"error"`
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+// setupAPI registers the JSON API routes on mux. The routes changing config.yaml and graph.json
+// are only served in dev mode, since they are open to anyone who can reach the server.
+func setupAPI(mux *http.ServeMux, s *site) {
+	for _, prefix := range apiPrefixes {
+		mux.HandleFunc("GET "+prefix+"/pages", s.listPages)
+		mux.HandleFunc("GET "+prefix+"/pages/{key}", s.getPage)
+		mux.HandleFunc("GET "+prefix+"/page-order", s.getPageOrder)
+		mux.HandleFunc("GET "+prefix+"/search", s.search)
+		if serverMode == devMode {
+			mux.HandleFunc("POST "+prefix+"/pages", s.createPage)
+			mux.HandleFunc("PUT "+prefix+"/pages/{key}", s.updatePage)
+			mux.HandleFunc("DELETE "+prefix+"/pages/{key}", s.deletePage)
+			mux.HandleFunc("PUT "+prefix+"/page-order", s.reorderPages)
+		}
+		setupStoryAPI(mux, s, prefix)
+	}
+}
+
+// writeJSON writes value as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("%sError encoding JSON response: %s%s", Red, err, Reset)
+	}
+}
+
+// writeAPIError writes a JSON error response
+func writeAPIError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, args...)})
+}
+
+// decodeJSON decodes the request body into value, rejecting unknown fields so typos are reported.
+// The body must be sent as application/json, which a page of another site can only do with the
+// permission of the server, so such a page cannot change files through a plain form or fetch.
+func decodeJSON(w http.ResponseWriter, r *http.Request, value any) bool {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeAPIError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(value); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON body: %s", err)
+		return false
+	}
+	return true
+}
+
+// etagOf returns a strong ETag derived from the JSON encoding of value
+func etagOf(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("%sError computing ETag: %s%s", Red, err, Reset)
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// checkIfMatch enforces optimistic concurrency: the client must send the ETag of the version it
+// edited in If-Match, and the request is refused if the resource has changed since
+func checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		writeAPIError(w, http.StatusPreconditionRequired, "If-Match header with the resource ETag is required")
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			// A wildcard would overwrite whatever version is saved, which is what If-Match prevents
+			writeAPIError(w, http.StatusPreconditionRequired, "If-Match header with the resource ETag is required, * is not accepted")
+			return false
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	w.Header().Set("ETag", etag)
+	writeAPIError(w, http.StatusPreconditionFailed, "resource was modified by someone else, fetch it again and retry")
+	return false
+}
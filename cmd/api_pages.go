@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pageResource is the JSON representation of a page in the API
+type pageResource struct {
+	Key string `json:"key"`
+	URL string `json:"url"`
+	PageConfig
+}
+
+// pageOrder is the JSON body used to read and change the navigation order of the pages
+type pageOrder struct {
+	Order []string `json:"order"`
+}
+
+// newPageResource builds the API representation of the page stored under key
+func newPageResource(key string, page PageConfig) pageResource {
+	return pageResource{Key: key, URL: "/models/" + pageSlug(key, page), PageConfig: page}
+}
+
+// listPages returns every page in navigation order
+func (s *site) listPages(w http.ResponseWriter, r *http.Request) {
+	config := s.state.Load().config
+	pages := make([]pageResource, 0, len(config.Pages))
+	for _, key := range sortedPageKeys(config.Pages) {
+		pages = append(pages, newPageResource(key, config.Pages[key]))
+	}
+
+	w.Header().Set("ETag", etagOf(pages))
+	writeJSON(w, http.StatusOK, pages)
+}
+
+// getPage returns a single page along with its ETag
+func (s *site) getPage(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	page, ok := s.state.Load().config.Pages[key]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "page %s not found", key)
+		return
+	}
+
+	w.Header().Set("ETag", etagOf(page))
+	writeJSON(w, http.StatusOK, newPageResource(key, page))
+}
+
+// createPage adds a new page, naming it after the next page number if no key is given
+func (s *site) createPage(w http.ResponseWriter, r *http.Request) {
+	var body pageResource
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	config := cloneConfig(s.state.Load().config)
+	key := body.Key
+	if key == "" {
+		key = nextPageKey(config.Pages)
+	}
+	if _, exists := config.Pages[key]; exists {
+		writeAPIError(w, http.StatusConflict, "page %s already exists", key)
+		return
+	}
+
+	config.Pages[key] = body.PageConfig
+	if !s.savePages(w, config, key) {
+		return
+	}
+
+	w.Header().Set("Location", apiPrefixes[0]+"/pages/"+key)
+	w.Header().Set("ETag", etagOf(body.PageConfig))
+	writeJSON(w, http.StatusCreated, newPageResource(key, body.PageConfig))
+}
+
+// updatePage replaces a page, provided it has not changed since the client fetched it
+func (s *site) updatePage(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var body pageResource
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	if body.Key != "" && body.Key != key {
+		writeJSON(w, http.StatusBadRequest, apiError{
+			Error:  "validation failed",
+			Fields: []fieldError{{Field: "key", Message: "cannot be changed, create a new page instead"}},
+		})
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	config := cloneConfig(s.state.Load().config)
+	current, ok := config.Pages[key]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "page %s not found", key)
+		return
+	}
+	if !checkIfMatch(w, r, etagOf(current)) {
+		return
+	}
+
+	config.Pages[key] = body.PageConfig
+	if !s.savePages(w, config, key) {
+		return
+	}
+
+	w.Header().Set("ETag", etagOf(body.PageConfig))
+	writeJSON(w, http.StatusOK, newPageResource(key, body.PageConfig))
+}
+
+// deletePage removes a page, provided it has not changed since the client fetched it and no
+// story node refers to it
+func (s *site) deletePage(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	config := cloneConfig(s.state.Load().config)
+	current, ok := config.Pages[key]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "page %s not found", key)
+		return
+	}
+	if !checkIfMatch(w, r, etagOf(current)) {
+		return
+	}
+
+	// Story nodes about the page would no longer load, so they must be changed first
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	var nodes []StoryNode
+	for _, node := range graph.Nodes {
+		if ref, ok := resolvePageRef(config.Pages, node.Page); ok && ref == key {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) > 0 {
+		writeJSON(w, http.StatusConflict, struct {
+			apiError
+			Nodes []StoryNode `json:"nodes"`
+		}{
+			apiError: apiError{Error: fmt.Sprintf("page %s is referenced by story nodes, change their page first", key)},
+			Nodes:    nodes,
+		})
+		return
+	}
+
+	delete(config.Pages, key)
+	if !s.savePages(w, config, "") {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPageOrder returns the page keys in navigation order
+func (s *site) getPageOrder(w http.ResponseWriter, r *http.Request) {
+	order := pageOrder{Order: sortedPageKeys(s.state.Load().config.Pages)}
+	w.Header().Set("ETag", etagOf(order))
+	writeJSON(w, http.StatusOK, order)
+}
+
+// reorderPages sets the Order of every page from a complete list of page keys
+func (s *site) reorderPages(w http.ResponseWriter, r *http.Request) {
+	var body pageOrder
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	config := cloneConfig(s.state.Load().config)
+	if !checkIfMatch(w, r, etagOf(pageOrder{Order: sortedPageKeys(config.Pages)})) {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range body.Order {
+		if _, ok := config.Pages[key]; !ok || seen[key] {
+			writeJSON(w, http.StatusBadRequest, apiError{
+				Error:  "validation failed",
+				Fields: []fieldError{{Field: "order", Message: fmt.Sprintf("page %s is unknown or listed twice", key)}},
+			})
+			return
+		}
+		seen[key] = true
+	}
+	if len(seen) != len(config.Pages) {
+		writeJSON(w, http.StatusBadRequest, apiError{
+			Error:  "validation failed",
+			Fields: []fieldError{{Field: "order", Message: "must list every page exactly once"}},
+		})
+		return
+	}
+
+	for i, key := range body.Order {
+		page := config.Pages[key]
+		page.Order = i + 1
+		config.Pages[key] = page
+	}
+	if !s.savePages(w, config, "") {
+		return
+	}
+
+	order := pageOrder{Order: sortedPageKeys(config.Pages)}
+	w.Header().Set("ETag", etagOf(order))
+	writeJSON(w, http.StatusOK, order)
+}
+
+// savePages validates config as a reload of config.yaml would, reporting the fields of the page
+// stored under key, if any, on their own, then serves and persists it. Model files not added
+// yet are only logged as warnings, as on reload. It writes an error response and returns false
+// if the pages could not be saved.
+func (s *site) savePages(w http.ResponseWriter, config Config, key string) bool {
+	if key != "" {
+		var errs []fieldError
+		for _, fe := range validatePage(config.Pages[key]) {
+			if !fe.Missing {
+				errs = append(errs, fe)
+			}
+		}
+		if !slugPattern.MatchString(key) {
+			errs = append(errs, fieldError{Field: "key", Message: "may only contain letters, digits, '-' and '_'"})
+		}
+		if _, err := pageKeysBySlug(config.Pages); err != nil {
+			errs = append(errs, fieldError{Field: "Slug", Message: err.Error()})
+		}
+		if len(errs) > 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "validation failed", Fields: errs})
+			return false
+		}
+	}
+
+	data, err := yaml.Marshal(&config)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "marshalling config: %s", err)
+		return false
+	}
+	problems, missing := splitMissing(validateConfigData(configPath, data))
+	if len(problems) > 0 {
+		errs := make([]fieldError, len(problems))
+		for i, p := range problems {
+			errs[i] = fieldError{Field: "config", Message: p.Message}
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "validation failed", Fields: errs})
+		return false
+	}
+
+	// Serve the pages first, so config.yaml is only written once they are known to render
+	previous := s.state.Load().config
+	if err := s.swap(config); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "reloading config: %s", err)
+		return false
+	}
+	if err := writeConfig(configPath, config); err != nil {
+		if err := s.swap(previous); err != nil {
+			log.Printf("%sError restoring the previous config: %s%s", Red, err, Reset)
+		}
+		writeAPIError(w, http.StatusInternalServerError, "saving config: %s", err)
+		return false
+	}
+	logMissing(missing)
+	return true
+}
+
+// cloneConfig copies config so it can be modified without affecting the served version
+func cloneConfig(config Config) Config {
+	pages := make(map[string]PageConfig, len(config.Pages))
+	for key, page := range config.Pages {
+		pages[key] = page
+	}
+	config.Pages = pages
+	return config
+}
+
+// nextPageKey returns the key following the highest numbered page, e.g. page4 after page3
+func nextPageKey(pages map[string]PageConfig) string {
+	numbers := []int{0}
+	for key := range pages {
+		if number, err := extractNumber(key); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return fmt.Sprintf("page%d", numbers[len(numbers)-1]+1)
+}
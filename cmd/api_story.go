@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// setupStoryAPI registers the story graph routes of the JSON API under prefix, the ones
+// changing it only in dev mode
+func setupStoryAPI(mux *http.ServeMux, s *site, prefix string) {
+	mux.HandleFunc("GET "+prefix+"/story", s.getStory)
+	mux.HandleFunc("GET "+prefix+"/story/nodes/{id}", s.getStoryNode)
+	mux.HandleFunc("GET "+prefix+"/story/links/{source}/{target}", s.getStoryLink)
+	if serverMode != devMode {
+		return
+	}
+	mux.HandleFunc("POST "+prefix+"/story/nodes", s.createStoryNode)
+	mux.HandleFunc("PUT "+prefix+"/story/nodes/{id}", s.updateStoryNode)
+	mux.HandleFunc("DELETE "+prefix+"/story/nodes/{id}", s.deleteStoryNode)
+	mux.HandleFunc("POST "+prefix+"/story/links", s.createStoryLink)
+	mux.HandleFunc("PUT "+prefix+"/story/links/{source}/{target}", s.updateStoryLink)
+	mux.HandleFunc("DELETE "+prefix+"/story/links/{source}/{target}", s.deleteStoryLink)
+}
+
+// getStory returns the story graph, narrowed to the nodes around a keyword if one is given
+func (s *site) getStory(w http.ResponseWriter, r *http.Request) {
+	depth, err := parseStoryDepth(r.URL.Query().Get("depth"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "%s", err)
+		return
+	}
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	graph = filterStoryGraph(graph, r.URL.Query().Get("keyword"), depth)
+
+	w.Header().Set("ETag", etagOf(graph))
+	writeJSON(w, http.StatusOK, graph)
+}
+
+// getStoryNode returns a single node along with its ETag
+func (s *site) getStoryNode(w http.ResponseWriter, r *http.Request) {
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+
+	id := NodeID(r.PathValue("id"))
+	i := graph.node(id)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, "node %s not found", id)
+		return
+	}
+
+	w.Header().Set("ETag", etagOf(graph.Nodes[i]))
+	writeJSON(w, http.StatusOK, graph.Nodes[i])
+}
+
+// createStoryNode adds a node, numbering it after the highest numeric id if no id is given
+func (s *site) createStoryNode(w http.ResponseWriter, r *http.Request) {
+	var node StoryNode
+	if !decodeJSON(w, r, &node) {
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	if node.ID == "" {
+		node.ID = nextNodeID(graph)
+	}
+	if graph.node(node.ID) >= 0 {
+		writeAPIError(w, http.StatusConflict, "node %s already exists", node.ID)
+		return
+	}
+	if errs := s.validateStoryNode(node); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "validation failed", Fields: errs})
+		return
+	}
+
+	graph.Nodes = append(graph.Nodes, node)
+	if !s.saveStory(w, graph) {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/story/nodes/%s", apiPrefixes[0], node.ID))
+	w.Header().Set("ETag", etagOf(node))
+	writeJSON(w, http.StatusCreated, node)
+}
+
+// updateStoryNode replaces a node, provided it has not changed since the client fetched it
+func (s *site) updateStoryNode(w http.ResponseWriter, r *http.Request) {
+	id := NodeID(r.PathValue("id"))
+	var node StoryNode
+	if !decodeJSON(w, r, &node) {
+		return
+	}
+	if node.ID == "" {
+		node.ID = id
+	}
+	if node.ID != id {
+		writeJSON(w, http.StatusBadRequest, apiError{
+			Error:  "validation failed",
+			Fields: []fieldError{{Field: "id", Message: "cannot be changed, create a new node instead"}},
+		})
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	i := graph.node(id)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, "node %s not found", id)
+		return
+	}
+	if !checkIfMatch(w, r, etagOf(graph.Nodes[i])) {
+		return
+	}
+	if errs := s.validateStoryNode(node); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "validation failed", Fields: errs})
+		return
+	}
+
+	graph.Nodes[i] = node
+	if !s.saveStory(w, graph) {
+		return
+	}
+
+	w.Header().Set("ETag", etagOf(node))
+	writeJSON(w, http.StatusOK, node)
+}
+
+// deleteStoryNode removes a node. Links to it would be left dangling, so the request is
+// rejected while any exist unless cascade=true asks for them to be removed as well.
+func (s *site) deleteStoryNode(w http.ResponseWriter, r *http.Request) {
+	id := NodeID(r.PathValue("id"))
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	i := graph.node(id)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, "node %s not found", id)
+		return
+	}
+	if !checkIfMatch(w, r, etagOf(graph.Nodes[i])) {
+		return
+	}
+
+	links := graph.linksOf(id)
+	if len(links) > 0 && r.URL.Query().Get("cascade") != "true" {
+		writeJSON(w, http.StatusConflict, struct {
+			apiError
+			Links []StoryLink `json:"links"`
+		}{
+			apiError: apiError{Error: fmt.Sprintf("node %s is still linked, delete its links first or pass cascade=true", id)},
+			Links:    links,
+		})
+		return
+	}
+
+	graph.Nodes = append(graph.Nodes[:i], graph.Nodes[i+1:]...)
+	remaining := graph.Links[:0]
+	for _, link := range graph.Links {
+		if link.Source != id && link.Target != id {
+			remaining = append(remaining, link)
+		}
+	}
+	graph.Links = remaining
+
+	if !s.saveStory(w, graph) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createStoryLink connects two existing nodes
+func (s *site) createStoryLink(w http.ResponseWriter, r *http.Request) {
+	var link StoryLink
+	if !decodeJSON(w, r, &link) {
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	if errs := validateStoryLink(graph, link); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "validation failed", Fields: errs})
+		return
+	}
+	if graph.link(link.Source, link.Target) >= 0 {
+		writeAPIError(w, http.StatusConflict, "nodes %s and %s are already linked", link.Source, link.Target)
+		return
+	}
+
+	graph.Links = append(graph.Links, link)
+	if !s.saveStory(w, graph) {
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/story/links/%s/%s", apiPrefixes[0], link.Source, link.Target))
+	w.Header().Set("ETag", etagOf(link))
+	writeJSON(w, http.StatusCreated, link)
+}
+
+// getStoryLink returns the link between two nodes along with its ETag
+func (s *site) getStoryLink(w http.ResponseWriter, r *http.Request) {
+	source, target := NodeID(r.PathValue("source")), NodeID(r.PathValue("target"))
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	i := graph.link(source, target)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, "no link from node %s to node %s", source, target)
+		return
+	}
+
+	w.Header().Set("ETag", etagOf(graph.Links[i]))
+	writeJSON(w, http.StatusOK, graph.Links[i])
+}
+
+// updateStoryLink moves a link to other nodes
+func (s *site) updateStoryLink(w http.ResponseWriter, r *http.Request) {
+	source, target := NodeID(r.PathValue("source")), NodeID(r.PathValue("target"))
+	var link StoryLink
+	if !decodeJSON(w, r, &link) {
+		return
+	}
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	i := graph.link(source, target)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, "no link from node %s to node %s", source, target)
+		return
+	}
+	if !checkIfMatch(w, r, etagOf(graph.Links[i])) {
+		return
+	}
+	if errs := validateStoryLink(graph, link); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "validation failed", Fields: errs})
+		return
+	}
+	if j := graph.link(link.Source, link.Target); j >= 0 && j != i {
+		writeAPIError(w, http.StatusConflict, "nodes %s and %s are already linked", link.Source, link.Target)
+		return
+	}
+
+	graph.Links[i] = link
+	if !s.saveStory(w, graph) {
+		return
+	}
+
+	w.Header().Set("ETag", etagOf(link))
+	writeJSON(w, http.StatusOK, link)
+}
+
+// deleteStoryLink removes the link between two nodes
+func (s *site) deleteStoryLink(w http.ResponseWriter, r *http.Request) {
+	source, target := NodeID(r.PathValue("source")), NodeID(r.PathValue("target"))
+
+	s.editMu.Lock()
+	defer s.editMu.Unlock()
+
+	graph, ok := loadStoryGraph(w)
+	if !ok {
+		return
+	}
+	i := graph.link(source, target)
+	if i < 0 {
+		writeAPIError(w, http.StatusNotFound, "no link from node %s to node %s", source, target)
+		return
+	}
+	if !checkIfMatch(w, r, etagOf(graph.Links[i])) {
+		return
+	}
+
+	graph.Links = append(graph.Links[:i], graph.Links[i+1:]...)
+	if !s.saveStory(w, graph) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadStoryGraph reads the story graph, writing an error response if it cannot be loaded
+func loadStoryGraph(w http.ResponseWriter) (StoryGraph, bool) {
+	graph, err := readStoryGraph(storyGraphPath)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "reading story graph: %s", err)
+		return graph, false
+	}
+	return graph, true
+}
+
+// validateStoryNode checks a node, including that the page it refers to is served
+func (s *site) validateStoryNode(node StoryNode) []fieldError {
+	return append(validateStoryNode(node), validateStoryPage(s.state.Load().config.Pages, node)...)
+}
+
+// saveStory writes the story graph and serves it, writing an error response if it cannot be saved
+func (s *site) saveStory(w http.ResponseWriter, graph StoryGraph) bool {
+	if err := writeStoryGraph(storyGraphPath, graph); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "saving story graph: %s", err)
+		return false
+	}
+	if err := s.swapStory(graph); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "reloading story graph: %s", err)
+		return false
+	}
+	return true
+}
+
+// nextNodeID returns the id following the highest numeric node id
+func nextNodeID(graph StoryGraph) NodeID {
+	highest := 0
+	for _, node := range graph.Nodes {
+		if n, err := strconv.Atoi(string(node.ID)); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return NodeID(strconv.Itoa(highest + 1))
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStoryAPI(t *testing.T) {
+	server, _ := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1")}})
+
+	original := storyGraphPath
+	storyGraphPath = filepath.Join(t.TempDir(), "graph.json")
+	t.Cleanup(func() { storyGraphPath = original })
+	os.WriteFile(storyGraphPath, []byte(`{"nodes": [{"id": 1, "keyword": "Intro", "story": "Once"}, {"id": "end", "keyword": "End", "story": "Done"}],
+		"links": [{"source": 1, "target": "end"}]}`), 0644)
+
+	resp, body := doJSON(t, "GET", server.URL+"/api/story", "", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" || !strings.Contains(body, `"id":1,`) {
+		t.Fatalf("Unexpected story graph: %d %s", resp.StatusCode, body)
+	}
+
+	// Nodes without an id are numbered after the highest numeric id
+	resp, body = doJSON(t, "POST", server.URL+"/api/v1/story/nodes", `{"keyword": "Middle", "story": "Then"}`, "")
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/api/v1/story/nodes/2" {
+		t.Fatalf("Expected node to be created, got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := doJSON(t, "POST", server.URL+"/api/story/nodes", `{"id": 2, "keyword": "Again"}`, ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected duplicate id to be refused, got %d", resp.StatusCode)
+	}
+	resp, body = doJSON(t, "POST", server.URL+"/api/story/nodes", `{"story": "No keyword"}`, "")
+	var apiErr apiError
+	json.Unmarshal([]byte(body), &apiErr)
+	if resp.StatusCode != http.StatusBadRequest || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "keyword" {
+		t.Fatalf("Expected validation error, got %d %s", resp.StatusCode, body)
+	}
+
+	// Links must connect existing nodes and may not be duplicated
+	if resp, body := doJSON(t, "POST", server.URL+"/api/story/links", `{"source": 2, "target": 9}`, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected link to unknown node to be refused, got %d %s", resp.StatusCode, body)
+	}
+	resp, body = doJSON(t, "POST", server.URL+"/api/story/links", `{"source": 1, "target": 2}`, "")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected link to be created, got %d %s", resp.StatusCode, body)
+	}
+	linkETag := resp.Header.Get("ETag")
+	if resp, _ := doJSON(t, "POST", server.URL+"/api/story/links", `{"source": 1, "target": 2}`, ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected duplicate link to be refused, got %d", resp.StatusCode)
+	}
+
+	// Link changes require the ETag of the link too
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/story/links/1/2", `{"source": 2, "target": "end"}`, ""); resp.StatusCode != http.StatusPreconditionRequired {
+		t.Fatalf("Expected status 428, got %d", resp.StatusCode)
+	}
+	if resp, body := doJSON(t, "PUT", server.URL+"/api/story/links/1/2", `{"source": 2, "target": "end"}`, linkETag); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected link to be moved, got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := doJSON(t, "DELETE", server.URL+"/api/story/links/2/end", "", ""); resp.StatusCode != http.StatusPreconditionRequired {
+		t.Fatalf("Expected status 428, got %d", resp.StatusCode)
+	}
+	if resp, _ := doJSON(t, "DELETE", server.URL+"/api/story/links/2/end", "", linkETag); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected stale ETag to be refused, got %d", resp.StatusCode)
+	}
+	if resp, _ := doJSON(t, "GET", server.URL+"/api/story/links/2/end", "", ""); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == linkETag {
+		t.Fatalf("Expected moved link with a new ETag, got %d", resp.StatusCode)
+	}
+
+	// Node updates require the ETag of the version being edited and keep the id
+	resp, body = doJSON(t, "GET", server.URL+"/api/story/nodes/2", "", "")
+	etag := resp.Header.Get("ETag")
+	edited := strings.Replace(body, "Middle", "Climax", 1)
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/story/nodes/2", edited, ""); resp.StatusCode != http.StatusPreconditionRequired {
+		t.Fatalf("Expected status 428, got %d", resp.StatusCode)
+	}
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/story/nodes/2", `{"id": 3, "keyword": "Moved"}`, etag); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected id change to be refused, got %d", resp.StatusCode)
+	}
+	if resp, body := doJSON(t, "PUT", server.URL+"/api/story/nodes/2", edited, etag); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected node to be updated, got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/story/nodes/2", edited, etag); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected stale ETag to be refused, got %d", resp.StatusCode)
+	}
+
+	// Linked nodes are only deleted along with their links
+	resp, _ = doJSON(t, "GET", server.URL+"/api/story/nodes/end", "", "")
+	etag = resp.Header.Get("ETag")
+	resp, body = doJSON(t, "DELETE", server.URL+"/api/story/nodes/end", "", etag)
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(body, `"links":[`) {
+		t.Fatalf("Expected linked node deletion to be refused, got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := doJSON(t, "DELETE", server.URL+"/api/story/nodes/end?cascade=true", "", etag); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected node to be deleted, got %d", resp.StatusCode)
+	}
+
+	graph, err := readStoryGraph(storyGraphPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(graph.Nodes) != 2 || graph.Nodes[1].Keyword != "Climax" || len(graph.Links) != 0 {
+		t.Fatalf("Unexpected saved graph: %+v", graph)
+	}
+	if problems := validateGraphFile(storyGraphPath, nil); len(problems) != 0 {
+		t.Fatalf("Expected saved graph to be valid, got %v", problems)
+	}
+
+	if resp, _ := doJSON(t, "DELETE", server.URL+"/api/story/links/1/2", "", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected missing link to be reported, got %d", resp.StatusCode)
+	}
+}
+
+func TestNodeIDJSON(t *testing.T) {
+	graph := StoryGraph{
+		Nodes: []StoryNode{{ID: "1"}, {ID: "-2"}, {ID: "007"}, {ID: "-0"}, {ID: "+3"}, {ID: "intro"}},
+		Links: []StoryLink{{"007", "1"}},
+	}
+	data, err := json.Marshal(graph)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, expected := range []string{`"id":1,`, `"id":-2,`, `"id":"007"`, `"id":"-0"`, `"id":"+3"`, `"id":"intro"`, `"source":"007"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in %s", expected, data)
+		}
+	}
+
+	var decoded StoryGraph
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if !reflect.DeepEqual(decoded, graph) {
+		t.Errorf("Expected %v after a round trip, got %v", graph, decoded)
+	}
+}
+
+func TestFilterStoryGraph(t *testing.T) {
+	graph := StoryGraph{
+		Nodes: []StoryNode{
+			{ID: "1", Keyword: "Introduction", Story: "Our hero sets out."},
+			{ID: "2", Keyword: "Conflict", Story: "A dragon appears."},
+			{ID: "3", Keyword: "Resolution", Story: "The dragon is tamed."},
+			{ID: "4", Keyword: "Epilogue", Story: "Years later."},
+		},
+		Links: []StoryLink{{"1", "2"}, {"2", "3"}, {"3", "4"}},
+	}
+
+	tests := []struct {
+		query string
+		depth int
+		nodes []NodeID
+	}{
+		{"intro", 0, []NodeID{"1"}},
+		{"INTRO", 1, []NodeID{"1", "2"}},
+		{"hero", 2, []NodeID{"1", "2", "3"}},
+		{"dragon tamed", 0, []NodeID{"3"}},
+		{"dragon", 1, []NodeID{"1", "2", "3", "4"}},
+		{"ragon", 1, nil},
+		{"", 0, []NodeID{"1", "2", "3", "4"}},
+	}
+
+	for _, tt := range tests {
+		filtered := filterStoryGraph(graph, tt.query, tt.depth)
+		var ids []NodeID
+		for _, node := range filtered.Nodes {
+			ids = append(ids, node.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.nodes) {
+			t.Errorf("filterStoryGraph(%q, %d) = %v, expected %v", tt.query, tt.depth, ids, tt.nodes)
+		}
+		for _, link := range filtered.Links {
+			if filtered.node(link.Source) < 0 || filtered.node(link.Target) < 0 {
+				t.Errorf("filterStoryGraph(%q, %d) kept dangling link %v", tt.query, tt.depth, link)
+			}
+		}
+	}
+}
+
+func TestStoryKeyword(t *testing.T) {
+	server, _ := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1")}})
+
+	original := storyGraphPath
+	storyGraphPath = filepath.Join(t.TempDir(), "graph.json")
+	t.Cleanup(func() { storyGraphPath = original })
+	os.WriteFile(storyGraphPath, []byte(`{"nodes": [{"id": 1, "keyword": "Introduction", "story": "Once"},
+		{"id": 2, "keyword": "Conflict", "story": "<b>Then</b>"}, {"id": 3, "keyword": "Resolution", "story": "Done"}],
+		"links": [{"source": 1, "target": 2}, {"source": 2, "target": 3}]}`), 0644)
+
+	_, body := doJSON(t, "GET", server.URL+"/story?keyword=intro", "", "")
+	start := strings.Index(body, `<script type="application/json" id="graph-data">`)
+	if start < 0 {
+		t.Fatalf("Expected filtered graph to be embedded, got %s", body)
+	}
+	data := body[start+len(`<script type="application/json" id="graph-data">`):]
+	data = data[:strings.Index(data, "</script>")]
+
+	var embedded StoryGraph
+	if err := json.Unmarshal([]byte(data), &embedded); err != nil {
+		t.Fatalf("Expected embedded graph to be JSON, got %v: %s", err, data)
+	}
+	if len(embedded.Nodes) != 2 || embedded.Nodes[1].Story != "<b>Then</b>" || len(embedded.Links) != 1 {
+		t.Fatalf("Unexpected embedded graph: %+v", embedded)
+	}
+
+	if _, body := doJSON(t, "GET", server.URL+"/story", "", ""); strings.Contains(body, "graph-data") {
+		t.Fatal("Expected the whole graph to be fetched when no keyword is given")
+	}
+
+	resp, body := doJSON(t, "GET", server.URL+"/api/story?keyword=resol&depth=0", "", "")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"nodes":[{"id":3,`) || !strings.Contains(body, `"links":[]`) {
+		t.Fatalf("Unexpected filtered graph: %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := doJSON(t, "GET", server.URL+"/api/story?keyword=resol&depth=-1", "", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected invalid depth to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestPageStory(t *testing.T) {
+	useTestUIFiles(t)
+	second := testPage("Model 2")
+	second.Slug = "second"
+	config := Config{Pages: map[string]PageConfig{"page1": testPage("Model 1"), "page2": second}}
+	graph := StoryGraph{
+		Nodes: []StoryNode{
+			{ID: "1", Keyword: "Found", Story: "It was found.", Page: "page1"},
+			{ID: "2", Keyword: "Traded", Story: "It was traded."},
+			{ID: "3", Keyword: "Shown", Story: "It was shown.", Page: "second"},
+		},
+		Links: []StoryLink{{"1", "2"}, {"3", "1"}},
+	}
+
+	appearances := pageStory(config, graph, "page1")
+	expected := []storyAppearance{{
+		Keyword:    "Found",
+		Story:      "It was found.",
+		Neighbours: []storyNeighbour{{Keyword: "Traded"}, {Keyword: "Shown", PageSlug: "second"}},
+	}}
+	if !reflect.DeepEqual(appearances, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, appearances)
+	}
+
+	// Pages can be referred to by slug and the panel is rendered on them
+	var buf strings.Builder
+	if err := renderPage(&buf, parseTemplates(), config, graph, "page2", "plain"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	html := buf.String()
+	if !strings.Contains(html, "Appears in story") || !strings.Contains(html, `href="/story?keyword=Shown"`) || !strings.Contains(html, `href="/models/page1"`) {
+		t.Fatalf("Expected story panel on the page, got %s", html)
+	}
+	buf.Reset()
+	renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page2", "card")
+	if strings.Contains(buf.String(), "Appears in story") {
+		t.Fatal("Expected no story panel on a page without story nodes")
+	}
+
+	graph.Nodes[1].Page = "missing"
+	if errs := validateStoryPage(config.Pages, graph.Nodes[1]); len(errs) != 1 || errs[0].Field != "page" {
+		t.Fatalf("Expected unknown page to be reported, got %v", errs)
+	}
+}
+
+func TestStoryAPIPageReference(t *testing.T) {
+	server, s := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1")}})
+
+	original := storyGraphPath
+	storyGraphPath = filepath.Join(t.TempDir(), "graph.json")
+	t.Cleanup(func() { storyGraphPath = original })
+	os.WriteFile(storyGraphPath, []byte(`{"nodes": [], "links": []}`), 0644)
+
+	if resp, _ := doJSON(t, "POST", server.URL+"/api/story/nodes", `{"keyword": "Lost", "page": "page9"}`, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected unknown page to be refused, got %d", resp.StatusCode)
+	}
+	if resp, body := doJSON(t, "POST", server.URL+"/api/story/nodes", `{"keyword": "Found", "story": "Here", "page": "page1"}`, ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected node to be created, got %d %s", resp.StatusCode, body)
+	}
+
+	// The model page shows the new node right away
+	if story := s.state.Load().story; len(story.Nodes) != 1 {
+		t.Fatalf("Expected story to be served, got %+v", story)
+	}
+	if _, body := doJSON(t, "GET", server.URL+"/models/page1", "", ""); !strings.Contains(body, "Appears in story") {
+		t.Fatal("Expected the page to list the story node")
+	}
+}
+
+func TestAPIStoryIsEscaped(t *testing.T) {
+	server, _ := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1")}})
+
+	original := storyGraphPath
+	storyGraphPath = filepath.Join(t.TempDir(), "graph.json")
+	t.Cleanup(func() { storyGraphPath = original })
+	os.WriteFile(storyGraphPath, []byte(`{"nodes": [], "links": []}`), 0644)
+
+	node := `{"keyword": "<b>Intro</b>", "story": "<img src=x onerror=alert(1)>", "page": "page1"}`
+	if resp, body := doJSON(t, "POST", server.URL+"/api/story/nodes", node, ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected node to be created, got %d %s", resp.StatusCode, body)
+	}
+
+	_, body := doJSON(t, "GET", server.URL+"/models/page1", "", "")
+	if strings.Contains(body, "<img src=x") || strings.Contains(body, "<b>Intro") {
+		t.Fatalf("Expected story text to be escaped, got %s", body)
+	}
+	if !strings.Contains(body, "&lt;img src=x onerror=alert(1)&gt;") {
+		t.Errorf("Expected escaped story text on the page, got %s", body)
+	}
+}
+
+func TestDeleteReferencedPage(t *testing.T) {
+	page := testPage("Model 2")
+	page.Slug = "second"
+	server, s := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1"), "page2": page}})
+
+	original := storyGraphPath
+	storyGraphPath = filepath.Join(t.TempDir(), "graph.json")
+	t.Cleanup(func() { storyGraphPath = original })
+	os.WriteFile(storyGraphPath, []byte(`{"nodes": [{"id": 1, "keyword": "Intro", "page": "second"}], "links": []}`), 0644)
+
+	resp, _ := doJSON(t, "GET", server.URL+"/api/pages/page2", "", "")
+	etag := resp.Header.Get("ETag")
+	resp, body := doJSON(t, "DELETE", server.URL+"/api/pages/page2", "", etag)
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(body, `"nodes":[{"id":1,`) {
+		t.Fatalf("Expected referenced page deletion to be refused, got %d %s", resp.StatusCode, body)
+	}
+	if _, ok := s.state.Load().config.Pages["page2"]; !ok {
+		t.Fatal("Expected referenced page to be kept")
+	}
+
+	resp, _ = doJSON(t, "GET", server.URL+"/api/story/nodes/1", "", "")
+	if resp, _ := doJSON(t, "DELETE", server.URL+"/api/story/nodes/1", "", resp.Header.Get("ETag")); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected node to be deleted, got %d", resp.StatusCode)
+	}
+	if resp, body := doJSON(t, "DELETE", server.URL+"/api/pages/page2", "", etag); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected unreferenced page to be deleted, got %d %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestAPIServer serves the API for a site backed by a temporary config file
+func newTestAPIServer(t *testing.T, config Config) (*httptest.Server, *site) {
+	useTestUIFiles(t)
+	original := configPath
+	configPath = filepath.Join(t.TempDir(), "config.yaml")
+	t.Cleanup(func() { configPath = original })
+	writeConfig(configPath, config)
+
+	s, err := newSite(config, StoryGraph{}, parseTemplates(), "card", true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	server := httptest.NewServer(setupHandlers(s))
+	t.Cleanup(server.Close)
+	return server, s
+}
+
+// doJSON sends a request with an optional JSON body and If-Match header
+func doJSON(t *testing.T, method string, url string, body string, ifMatch string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestPagesAPI(t *testing.T) {
+	server, s := newTestAPIServer(t, Config{
+		Pages: map[string]PageConfig{
+			"page1": testPage("Model 1"),
+			"page2": testPage("Model 2"),
+		},
+	})
+
+	resp, body := doJSON(t, "GET", server.URL+"/api/pages", "", "")
+	var pages []pageResource
+	json.Unmarshal([]byte(body), &pages)
+	if resp.StatusCode != http.StatusOK || len(pages) != 2 || pages[0].Key != "page1" || pages[1].URL != "/models/page2" {
+		t.Fatalf("Unexpected page list: %d %s", resp.StatusCode, body)
+	}
+
+	// Create a page, letting the server pick its key
+	newPage := `{"ModelSrcPath": "/static/models/obj1/object1.glb", "ModelIosSrcPath": "/static/models/obj1/object1.usdz",
+		"PosterPath": "/static/models/obj1/object1.webp", "ModelName": "Model 3", "Slug": "third"}`
+	resp, body = doJSON(t, "POST", server.URL+"/api/v1/pages", newPage, "")
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/api/v1/pages/page3" {
+		t.Fatalf("Expected page to be created, got %d %s", resp.StatusCode, body)
+	}
+	if page, ok := s.state.Load().config.Pages["page3"]; !ok || page.ModelName != "Model 3" {
+		t.Fatal("Expected created page to be served")
+	}
+	saved, _ := readConfig(configPath)
+	if _, ok := saved.Pages["page3"]; !ok {
+		t.Fatal("Expected created page to be saved to the config file")
+	}
+
+	// Invalid pages are rejected with the offending fields
+	resp, body = doJSON(t, "POST", server.URL+"/api/pages", `{"key": "bad", "ModelName": "Incomplete", "Slug": "third"}`, "")
+	var apiErr apiError
+	json.Unmarshal([]byte(body), &apiErr)
+	if resp.StatusCode != http.StatusBadRequest || len(apiErr.Fields) != 4 {
+		t.Fatalf("Expected validation errors, got %d %s", resp.StatusCode, body)
+	}
+	resp, _ = doJSON(t, "POST", server.URL+"/api/pages", `{"ModelNam": "Typo"}`, "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected unknown field to be rejected, got %d", resp.StatusCode)
+	}
+
+	// Other sites can post text/plain or untyped bodies without asking, so only JSON is accepted
+	for _, contentType := range []string{"text/plain", ""} {
+		resp, err := http.Post(server.URL+"/api/pages", contentType, strings.NewReader(`{"ModelName": "Forged"}`))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Fatalf("Expected a %q body to be rejected with 415, got %d", contentType, resp.StatusCode)
+		}
+	}
+
+	// Updates require the ETag of the version being edited
+	resp, body = doJSON(t, "GET", server.URL+"/api/pages/page1", "", "")
+	etag := resp.Header.Get("ETag")
+	edited := strings.Replace(body, "Model 1", "Renamed", 1)
+
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/pages/page1", edited, ""); resp.StatusCode != http.StatusPreconditionRequired {
+		t.Fatalf("Expected status 428, got %d", resp.StatusCode)
+	}
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/pages/page1", edited, "*"); resp.StatusCode != http.StatusPreconditionRequired {
+		t.Fatalf("Expected a wildcard ETag to be refused, got %d", resp.StatusCode)
+	}
+	if resp, body := doJSON(t, "PUT", server.URL+"/api/pages/page1", edited, etag); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected page to be updated, got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/pages/page1", edited, etag); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected stale ETag to be refused, got %d", resp.StatusCode)
+	}
+	if name := s.state.Load().config.Pages["page1"].ModelName; name != "Renamed" {
+		t.Fatalf("Expected page to be renamed, got %s", name)
+	}
+
+	// Reorder and delete
+	resp, _ = doJSON(t, "GET", server.URL+"/api/page-order", "", "")
+	resp, body = doJSON(t, "PUT", server.URL+"/api/page-order", `{"order": ["page3", "page1", "page2"]}`, resp.Header.Get("ETag"))
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `["page3","page1","page2"]`) {
+		t.Fatalf("Expected pages to be reordered, got %d %s", resp.StatusCode, body)
+	}
+
+	resp, _ = doJSON(t, "GET", server.URL+"/api/pages/page2", "", "")
+	if resp, _ := doJSON(t, "DELETE", server.URL+"/api/pages/page2", "", resp.Header.Get("ETag")); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected page to be deleted, got %d", resp.StatusCode)
+	}
+	if resp, _ := doJSON(t, "GET", server.URL+"/models/page2", "", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected deleted page to be gone, got %d", resp.StatusCode)
+	}
+}
+
+func TestSavePagesChecksWholeConfig(t *testing.T) {
+	broken := testPage("Model 2")
+	broken.DesignerWebsite = "not a url"
+	server, _ := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1"), "page2": broken}})
+
+	// Saving checks every page, as a reload of config.yaml would
+	resp, body := doJSON(t, "GET", server.URL+"/api/pages/page1", "", "")
+	edited := strings.Replace(body, "Model 1", "Renamed", 1)
+	resp, body = doJSON(t, "PUT", server.URL+"/api/pages/page1", edited, resp.Header.Get("ETag"))
+	var apiErr apiError
+	json.Unmarshal([]byte(body), &apiErr)
+	if resp.StatusCode != http.StatusBadRequest || len(apiErr.Fields) != 1 || !strings.Contains(apiErr.Fields[0].Message, "page page2: DesignerWebsite") {
+		t.Fatalf("Expected the other invalid page to be reported, got %d %s", resp.StatusCode, body)
+	}
+
+	// Model files not added yet are accepted, as they are on reload
+	newPage := `{"ModelSrcPath": "/static/models/obj9/object9.glb", "ModelIosSrcPath": "/static/models/obj9/object9.usdz",
+		"PosterPath": "/static/models/obj9/object9.webp", "ModelName": "Model 9"}`
+	resp, body = doJSON(t, "GET", server.URL+"/api/pages/page2", "", "")
+	fixed := strings.Replace(body, "not a url", "https://example.com", 1)
+	if resp, body := doJSON(t, "PUT", server.URL+"/api/pages/page2", fixed, resp.Header.Get("ETag")); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected page to be fixed, got %d %s", resp.StatusCode, body)
+	}
+	if resp, body := doJSON(t, "POST", server.URL+"/api/pages", newPage, ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected missing model files to be accepted, got %d %s", resp.StatusCode, body)
+	}
+
+	// A config that cannot be saved is not served either
+	configPath = filepath.Join(t.TempDir(), "missing", "config.yaml")
+	resp, body = doJSON(t, "GET", server.URL+"/api/pages/page1", "", "")
+	edited = strings.Replace(body, "Model 1", "Renamed", 1)
+	if resp, _ := doJSON(t, "PUT", server.URL+"/api/pages/page1", edited, resp.Header.Get("ETag")); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected saving to fail, got %d", resp.StatusCode)
+	}
+	if _, body := doJSON(t, "GET", server.URL+"/models/page1", "", ""); !strings.Contains(body, "Model 1") || strings.Contains(body, "Renamed") {
+		t.Errorf("Expected the saved page to be served, got %s", body)
+	}
+}
+
+func TestAPIPagesAreEscaped(t *testing.T) {
+	server, _ := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1")}})
+
+	newPage := `{"ModelSrcPath": "/static/models/obj1/object1.glb", "ModelIosSrcPath": "/static/models/obj1/object1.usdz",
+		"PosterPath": "/static/models/obj1/object1.webp", "ModelName": "<script>alert(1)</script>", "Description": "\"><img src=x onerror=alert(2)>"}`
+	if resp, body := doJSON(t, "POST", server.URL+"/api/pages", newPage, ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected page to be created, got %d %s", resp.StatusCode, body)
+	}
+
+	_, body := doJSON(t, "GET", server.URL+"/models/page2", "", "")
+	if strings.Contains(body, "<script>alert(1)") || strings.Contains(body, "<img src=x") {
+		t.Fatalf("Expected submitted markup to be escaped, got %s", body)
+	}
+	if !strings.Contains(body, "<title>&lt;script&gt;alert(1)&lt;/script&gt; - Sack</title>") {
+		t.Errorf("Expected escaped title, got %s", body)
+	}
+}
+
+func TestProdAPIIsReadOnly(t *testing.T) {
+	useTestMode(t, prodMode)
+	server, s := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": testPage("Model 1")}})
+
+	if resp, body := doJSON(t, "GET", server.URL+"/api/pages/page1", "", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected pages to be readable, got %d %s", resp.StatusCode, body)
+	}
+	for _, route := range []struct{ method, path string }{
+		{"POST", "/api/pages"},
+		{"PUT", "/api/v1/pages/page1"},
+		{"DELETE", "/api/pages/page1"},
+		{"PUT", "/api/page-order"},
+		{"POST", "/api/story/nodes"},
+		{"DELETE", "/api/story/nodes/1"},
+		{"PUT", "/api/story/links/1/2"},
+	} {
+		if resp, _ := doJSON(t, route.method, server.URL+route.path, `{"ModelName": "Changed"}`, "*"); resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected %s %s to be refused in prod mode, got %d", route.method, route.path, resp.StatusCode)
+		}
+	}
+	if name := s.state.Load().config.Pages["page1"].ModelName; name != "Model 1" {
+		t.Errorf("Expected the page to be unchanged, got %s", name)
+	}
+}
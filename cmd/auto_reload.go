@@ -1,12 +1,16 @@
 package main
 
 import (
-	"bufio"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/gorilla/websocket"
@@ -28,46 +32,116 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func parseGitignore(path string) ([]string, error) {
-	var patterns []string
+// The kinds of reload message sent to the browsers
+const (
+	reloadAll   = "reload" // reload the page
+	reloadCSS   = "css"    // swap in the stylesheets at Paths again
+	reloadModel = "model"  // fetch the models or posters at Paths again
+	reloadPages = "pages"  // reload the model pages whose slug is in Pages, and any other page
+	reloadError = "error"  // show Message, every change was rejected
+)
 
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+// reloadMessage tells the browsers what changed, so they only reload what they must
+type reloadMessage struct {
+	Type    string   `json:"type"`
+	Paths   []string `json:"paths,omitempty"` // URL paths of the static files, or the changed files
+	Pages   []string `json:"pages,omitempty"`
+	Message string   `json:"message,omitempty"` // why a change was rejected, if one was
+}
+
+// fileChangeMessage returns the message announcing a change to the file at path. Stylesheets
+// and models under a static directory can be swapped in place, anything else needs a reload.
+func fileChangeMessage(path string) reloadMessage {
+	slashed := "/" + filepath.ToSlash(filepath.Clean(path))
+	i := strings.Index(slashed, "/static/")
+	if i < 0 {
+		return reloadMessage{Type: reloadAll, Paths: []string{path}}
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue // Skip empty lines and comments
+	urlPath := slashed[i:]
+	switch strings.ToLower(filepath.Ext(urlPath)) {
+	case ".css":
+		return reloadMessage{Type: reloadCSS, Paths: []string{urlPath}}
+	case ".glb", ".gltf", ".usdz", ".bin", ".webp", ".png", ".jpg", ".jpeg":
+		if strings.HasPrefix(urlPath, "/static/models/") {
+			return reloadMessage{Type: reloadModel, Paths: []string{urlPath}}
 		}
-		patterns = append(patterns, line)
 	}
+	return reloadMessage{Type: reloadAll, Paths: []string{urlPath}}
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
+// clientSet tracks the browsers connected to the auto-reload WebSocket
+type clientSet struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]bool
+}
 
-	return patterns, nil
+// add registers a newly connected browser
+func (c *clientSet) add(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[conn] = true
 }
 
-func isIgnored(path string, patterns []string) bool {
-	for _, pattern := range patterns {
-		matched, err := filepath.Match(pattern, filepath.Base(path))
+// broadcast sends a message to every connected browser, dropping the ones that went away
+func (c *clientSet) broadcast(message reloadMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("%sWebSocket error: %v%s", Red, err, Reset)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for client := range c.conns {
+		err := client.WriteMessage(websocket.TextMessage, data)
 		if err != nil {
-			log.Printf("Error matching pattern: %v", err)
-			continue
+			log.Printf("%sWebSocket error: %v%s", Red, err, Reset)
+			client.Close()
+			delete(c.conns, client)
 		}
-		if matched {
-			return true
+	}
+}
+
+// fileChange is a file that changed during a batch, with every kind of event it saw
+type fileChange struct {
+	path string
+	op   fsnotify.Op
+}
+
+// describe lists the kinds of events of a change, e.g. "written, renamed"
+func (c fileChange) describe() string {
+	var kinds []string
+	for _, kind := range []struct {
+		op   fsnotify.Op
+		name string
+	}{{fsnotify.Create, "created"}, {fsnotify.Write, "written"}, {fsnotify.Remove, "removed"}, {fsnotify.Rename, "renamed"}} {
+		if c.op&kind.op != 0 {
+			kinds = append(kinds, kind.name)
 		}
 	}
-	return false
+	return strings.Join(kinds, ", ")
 }
 
-func reloadWatcher(watcher *fsnotify.Watcher, clients map[*websocket.Conn]bool, patterns []string) {
+// maxWaitFactor bounds how many quiet windows a batch may wait for, so that files changing
+// without a pause, such as a log being written, still reload the pages regularly
+const maxWaitFactor = 10
+
+// reloadWatcher gathers the relevant file events until none came for the quiet window, since
+// editors emit several per save, or until the batch waited for maxWaitFactor windows. It then
+// calls onChange once for every changed file and passes the browsers a single message for the
+// whole batch.
+func reloadWatcher(watcher *fsnotify.Watcher, clients *clientSet, ignore *ignoreMatcher, quiet time.Duration, onChange func(path string) (reloadMessage, error)) {
+	var batch []fileChange
+	seen := make(map[string]int) // index in batch by path
+	var settled, deadline <-chan time.Time
+
+	flush := func() {
+		clients.broadcast(applyChanges(batch, onChange))
+		batch, seen, settled, deadline = nil, make(map[string]int), nil, nil
+	}
+
 	for {
 		select {
 		case event, ok := <-watcher.Events:
@@ -75,7 +149,8 @@ func reloadWatcher(watcher *fsnotify.Watcher, clients map[*websocket.Conn]bool,
 				return
 			}
 
-			if isIgnored(event.Name, patterns) {
+			info, err := os.Stat(event.Name)
+			if ignore.Ignored(event.Name, err == nil && info.IsDir()) {
 				log.Printf("%sIgnoring file: %s%s", Yellow, event.Name, Reset)
 				continue
 			}
@@ -84,27 +159,22 @@ func reloadWatcher(watcher *fsnotify.Watcher, clients map[*websocket.Conn]bool,
 				continue
 			}
 
-			// Use different colors for different types of file events
-			switch {
-			case event.Op&fsnotify.Write != 0:
-				log.Printf("%sFile written: %s%s", Green, event.Name, Reset)
-			case event.Op&fsnotify.Create != 0:
-				log.Printf("%sFile created: %s%s", Blue, event.Name, Reset)
-			case event.Op&fsnotify.Remove != 0:
-				log.Printf("%sFile removed: %s%s", Red, event.Name, Reset)
-			case event.Op&fsnotify.Rename != 0:
-				log.Printf("%sFile renamed: %s%s", Purple, event.Name, Reset)
+			if len(batch) == 0 {
+				deadline = time.After(quiet * maxWaitFactor)
 			}
-
-			// Notify all connected WebSocket clients to reload
-			for client := range clients {
-				err := client.WriteMessage(websocket.TextMessage, []byte("reload"))
-				if err != nil {
-					log.Printf("%sWebSocket error: %v%s", Red, err, Reset)
-					client.Close()
-					delete(clients, client)
-				}
+			if i, ok := seen[event.Name]; ok {
+				batch[i].op |= event.Op
+			} else {
+				seen[event.Name] = len(batch)
+				batch = append(batch, fileChange{event.Name, event.Op})
 			}
+			settled = time.After(quiet)
+
+		case <-settled:
+			flush()
+
+		case <-deadline:
+			flush()
 
 		case err, ok := <-watcher.Errors:
 			if !ok {
@@ -115,22 +185,78 @@ func reloadWatcher(watcher *fsnotify.Watcher, clients map[*websocket.Conn]bool,
 	}
 }
 
-func setupWebSocket(watcher *fsnotify.Watcher) {
-	clients := make(map[*websocket.Conn]bool)
+// applyChanges logs a batch of changes in one line and calls onChange for each of them,
+// returning the message telling the browsers what to reload. Rejected changes are reported in
+// the Message of that reload, or of an error message if no change was accepted.
+func applyChanges(batch []fileChange, onChange func(path string) (reloadMessage, error)) reloadMessage {
+	described := make([]string, len(batch))
+	for i, change := range batch {
+		described[i] = fmt.Sprintf("%s (%s)", change.path, change.describe())
+	}
+	log.Printf("%s%d file(s) changed: %s%s", Green, len(batch), strings.Join(described, ", "), Reset)
 
-	gitignorePatterns, err := parseGitignore(".gitignore")
-	if err != nil {
-		log.Printf("Could not parse .gitignore: %v", err)
+	var messages []reloadMessage
+	var rejected []string
+	var paths []string
+	for _, change := range batch {
+		message, err := onChange(change.path)
+		if err != nil {
+			log.Printf("%sRejected change to %s, keeping the previous version:\n%s%s", Red, change.path, err, Reset)
+			rejected = append(rejected, err.Error())
+			paths = append(paths, change.path)
+			continue
+		}
+		messages = append(messages, message)
 	}
 
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	if len(messages) == 0 {
+		return reloadMessage{Type: reloadError, Paths: paths, Message: strings.Join(rejected, "\n\n")}
+	}
+	merged := mergeMessages(messages)
+	merged.Message = strings.Join(rejected, "\n\n")
+	return merged
+}
+
+// mergeMessages combines the messages of the files of a batch: messages of a kind add up their
+// paths or pages, while a batch mixing kinds reloads the page
+func mergeMessages(messages []reloadMessage) reloadMessage {
+	merged := messages[0]
+	for _, message := range messages[1:] {
+		if message.Type != merged.Type {
+			merged.Type = reloadAll
+		}
+		merged.Paths = appendMissing(merged.Paths, message.Paths...)
+		merged.Pages = appendMissing(merged.Pages, message.Pages...)
+	}
+	if merged.Type == reloadAll {
+		merged.Pages = nil
+	}
+	return merged
+}
+
+// appendMissing appends the values not in list yet
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !slices.Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// setupWebSocket serves the auto-reload WebSocket, telling the connected browsers about the
+// changes seen by watcher that are not ignored once they settled for the quiet window
+func setupWebSocket(mux *http.ServeMux, watcher *fsnotify.Watcher, ignore *ignoreMatcher, quiet time.Duration, onChange func(path string) (reloadMessage, error)) {
+	clients := &clientSet{conns: make(map[*websocket.Conn]bool)}
+
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Println("Upgrade error:", err)
 			return
 		}
-		clients[conn] = true
+		clients.add(conn)
 	})
 
-	go reloadWatcher(watcher, clients, gitignorePatterns)
+	go reloadWatcher(watcher, clients, ignore, quiet, onChange)
 }
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+func TestFileChangeMessage(t *testing.T) {
+	tests := map[string]reloadMessage{
+		"ui/static/css/card-layout.css":          {Type: reloadCSS, Paths: []string{"/static/css/card-layout.css"}},
+		"/srv/theme/static/css/dim.css":          {Type: reloadCSS, Paths: []string{"/static/css/dim.css"}},
+		"ui/static/models/obj1/object1.glb":      {Type: reloadModel, Paths: []string{"/static/models/obj1/object1.glb"}},
+		"./ui/static/models/obj1/object1.webp":   {Type: reloadModel, Paths: []string{"/static/models/obj1/object1.webp"}},
+		"ui/static/img/blue.png":                 {Type: reloadAll, Paths: []string{"/static/img/blue.png"}},
+		"ui/static/js/specs.js":                  {Type: reloadAll, Paths: []string{"/static/js/specs.js"}},
+		filepath.Join("ui", "html", "base.html"): {Type: reloadAll, Paths: []string{filepath.Join("ui", "html", "base.html")}},
+	}
+	for path, expected := range tests {
+		if message := fileChangeMessage(path); !reflect.DeepEqual(message, expected) {
+			t.Errorf("Expected %+v for %s, got %+v", expected, path, message)
+		}
+	}
+}
+
+func TestChangedPages(t *testing.T) {
+	config := func() Config {
+		return Config{Pages: map[string]PageConfig{"page1": testPage("Model 1"), "page2": testPage("Model 2")}}
+	}
+
+	edited := config()
+	page := edited.Pages["page2"]
+	page.Description = "A stone"
+	edited.Pages["page2"] = page
+	if slugs, ok := changedPages(config(), edited); !ok || !reflect.DeepEqual(slugs, []string{"page2"}) {
+		t.Errorf("Expected only page2 to change, got %v %v", slugs, ok)
+	}
+	if slugs, ok := changedPages(config(), config()); !ok || len(slugs) != 0 {
+		t.Errorf("Expected no page to change, got %v %v", slugs, ok)
+	}
+
+	for name, change := range map[string]func(*Config){
+		"added":   func(c *Config) { c.Pages["page3"] = testPage("Model 3") },
+		"removed": func(c *Config) { delete(c.Pages, "page1") },
+		"site":    func(c *Config) { c.Site.Title = "Stones" },
+		"slug": func(c *Config) {
+			page := c.Pages["page1"]
+			page.Slug = "stone"
+			c.Pages["page1"] = page
+		},
+	} {
+		changed := config()
+		change(&changed)
+		if slugs, ok := changedPages(config(), changed); ok {
+			t.Errorf("Expected a page %s to reload every page, got %v", name, slugs)
+		}
+	}
+}
+
+func TestMergeMessages(t *testing.T) {
+	css := func(path string) reloadMessage { return reloadMessage{Type: reloadCSS, Paths: []string{path}} }
+	pages := func(slugs ...string) reloadMessage {
+		return reloadMessage{Type: reloadPages, Paths: []string{"configs/config.yaml"}, Pages: slugs}
+	}
+
+	tests := []struct {
+		messages []reloadMessage
+		expected reloadMessage
+	}{
+		{[]reloadMessage{css("/static/css/a.css"), css("/static/css/b.css"), css("/static/css/a.css")}, reloadMessage{Type: reloadCSS, Paths: []string{"/static/css/a.css", "/static/css/b.css"}}},
+		{[]reloadMessage{pages("page1"), pages("page2", "page1")}, reloadMessage{Type: reloadPages, Paths: []string{"configs/config.yaml"}, Pages: []string{"page1", "page2"}}},
+		{[]reloadMessage{css("/static/css/a.css"), pages("page1")}, reloadMessage{Type: reloadAll, Paths: []string{"/static/css/a.css", "configs/config.yaml"}}},
+	}
+	for _, tt := range tests {
+		if merged := mergeMessages(tt.messages); !reflect.DeepEqual(merged, tt.expected) {
+			t.Errorf("Expected %+v, got %+v", tt.expected, merged)
+		}
+	}
+}
+
+func TestReloadWatcherBatches(t *testing.T) {
+	watcher := &fsnotify.Watcher{Events: make(chan fsnotify.Event), Errors: make(chan error)}
+	defer close(watcher.Events)
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	onChange := func(path string) (reloadMessage, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[path]++
+		if path == "configs/config.yaml" {
+			return reloadMessage{}, errors.New("Pages.page1.ModelName: required")
+		}
+		return fileChangeMessage(path), nil
+	}
+
+	mux := http.NewServeMux()
+	setupWebSocket(mux, watcher, newIgnoreMatcher(t.TempDir()), 50*time.Millisecond, onChange)
+	server := httptest.NewServer(injectWebSocketScriptMiddleware(mux))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Expected to connect, got %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(10 * time.Millisecond) // let the server register the connection
+
+	read := func() reloadMessage {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var message reloadMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Expected a message, got %v", err)
+		}
+		return message
+	}
+
+	// A save as editors make it: the file is renamed, created and written, twice over
+	for _, event := range []fsnotify.Event{
+		{Name: "ui/static/css/a.css", Op: fsnotify.Rename},
+		{Name: "ui/static/css/a.css", Op: fsnotify.Create},
+		{Name: "ui/static/css/a.css", Op: fsnotify.Chmod},
+		{Name: "ui/static/css/a.css", Op: fsnotify.Write},
+		{Name: "ui/static/css/b.css", Op: fsnotify.Write},
+		{Name: "ui/static/css/a.css", Op: fsnotify.Write},
+	} {
+		watcher.Events <- event
+	}
+	if message := read(); !reflect.DeepEqual(message, reloadMessage{Type: reloadCSS, Paths: []string{"/static/css/a.css", "/static/css/b.css"}}) {
+		t.Errorf("Expected a single message for the batch, got %+v", message)
+	}
+	mu.Lock()
+	if !reflect.DeepEqual(calls, map[string]int{"ui/static/css/a.css": 1, "ui/static/css/b.css": 1}) {
+		t.Errorf("Expected every changed file to be handled once, got %v", calls)
+	}
+	mu.Unlock()
+
+	// A rejected change is reported along with the accepted ones of its batch
+	watcher.Events <- fsnotify.Event{Name: "configs/config.yaml", Op: fsnotify.Write}
+	watcher.Events <- fsnotify.Event{Name: "ui/static/css/a.css", Op: fsnotify.Write}
+	expected := reloadMessage{Type: reloadCSS, Paths: []string{"/static/css/a.css"}, Message: "Pages.page1.ModelName: required"}
+	if message := read(); !reflect.DeepEqual(message, expected) {
+		t.Errorf("Expected the accepted change with the rejected one, got %+v", message)
+	}
+
+	// A batch of rejected changes only is an error
+	watcher.Events <- fsnotify.Event{Name: "configs/config.yaml", Op: fsnotify.Write}
+	if message := read(); message.Type != reloadError || !reflect.DeepEqual(message.Paths, []string{"configs/config.yaml"}) || message.Message != "Pages.page1.ModelName: required" {
+		t.Errorf("Expected the rejected change to be reported, got %+v", message)
+	}
+}
+
+func TestReloadWatcherMaxWait(t *testing.T) {
+	watcher := &fsnotify.Watcher{Events: make(chan fsnotify.Event), Errors: make(chan error)}
+	defer close(watcher.Events)
+	onChange := func(path string) (reloadMessage, error) { return fileChangeMessage(path), nil }
+
+	const quiet = 20 * time.Millisecond
+	mux := http.NewServeMux()
+	setupWebSocket(mux, watcher, newIgnoreMatcher(t.TempDir()), quiet, onChange)
+	server := httptest.NewServer(injectWebSocketScriptMiddleware(mux))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Expected to connect, got %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(10 * time.Millisecond) // let the server register the connection
+
+	// A file written more often than the quiet window never settles
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			case watcher.Events <- fsnotify.Event{Name: "ui/static/css/a.css", Op: fsnotify.Write}:
+				time.Sleep(quiet / 4)
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(quiet * maxWaitFactor * 5))
+	var message reloadMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("Expected the batch to be sent after the maximum wait, got %v", err)
+	}
+	if message.Type != reloadCSS {
+		t.Errorf("Expected the stylesheet to be swapped, got %+v", message)
+	}
+}
+
+func TestFileChangeDescribe(t *testing.T) {
+	if kinds := (fileChange{"a.css", fsnotify.Write | fsnotify.Rename | fsnotify.Create}).describe(); kinds != "created, written, renamed" {
+		t.Errorf("Expected every kind of event, got %q", kinds)
+	}
+}
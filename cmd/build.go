@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// linkAttrPattern matches root-relative URLs in the attributes that reference other files,
+// including the data attributes scripts swap in for variants and material textures
+var linkAttrPattern = regexp.MustCompile(`(\s(?:href|src|ios-src|poster|data-src|data-ios-src|data-poster|data-texture)=["'])(/[^"']*)(["'])`)
+
+// cleanOutDir empties outDir before a build, so files left by an earlier build cannot hide broken
+// links. A directory that is not empty is only emptied with force.
+func cleanOutDir(outDir string, force bool) error {
+	entries, err := os.ReadDir(outDir)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !force {
+		return fmt.Errorf("%s is not empty, pass --force to replace its content", outDir)
+	}
+
+	// Never delete the directory the site is built from
+	abs, err := filepath.Abs(outDir)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(abs, wd); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to empty %s, which holds the current directory", outDir)
+	}
+	return os.RemoveAll(outDir)
+}
+
+// buildSite renders every page of the site into outDir so it can be served by a static host
+func buildSite(config Config, story StoryGraph, tmpl *template.Template, layout string, outDir string) error {
+	// Copy static assets and configuration files
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	if err := copyDir(uiFiles, "static", filepath.Join(outDir, "static")); err != nil {
+		return fmt.Errorf("copying static files: %w", err)
+	}
+	for _, filename := range []string{configPath, storyGraphPath} {
+		data, err := readConfigFile(filename)
+		if err != nil {
+			return fmt.Errorf("copying %s: %w", filename, err)
+		}
+		if err := os.WriteFile(filepath.Join(outDir, filepath.Base(filename)), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	// Crawlers find the pages through the sitemap, which needs the public URL of the site
+	if err := os.WriteFile(filepath.Join(outDir, "robots.txt"), robotsTxt(config.Site.BaseURL), 0644); err != nil {
+		return err
+	}
+	if config.Site.BaseURL != "" {
+		sitemap, err := sitemapXML(config, config.Site.BaseURL)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(outDir, "sitemap.xml"), sitemap, 0644); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("%sSkipping sitemap.xml: set Site.BaseURL in the config to generate it%s", Yellow, Reset)
+	}
+
+	// Render the pages in every locale, the ones of other locales than the site's under their prefix
+	if _, err := pageKeysBySlug(config.Pages); err != nil {
+		return err
+	}
+	for _, locale := range availableLocales() {
+		if err := buildLocale(config, story, tmpl, layout, outDir, locale); err != nil {
+			return err
+		}
+	}
+
+	// Static hosts cannot answer with a 301, so the legacy routes get redirect pages instead
+	for number, key := range legacyPageNumbers(config.Pages) {
+		target := "/models/" + pageSlug(key, config.Pages[key])
+		if err := writeSitePage(outDir, "", fmt.Sprintf("model%d/index.html", number), redirectPage(target)); err != nil {
+			return err
+		}
+	}
+
+	return checkLinks(outDir)
+}
+
+// buildLocale renders the standalone pages and a page for each model in locale into the
+// directory of the locale inside outDir, e.g. zh/
+func buildLocale(config Config, story StoryGraph, tmpl *template.Template, layout string, outDir string, locale string) error {
+	root := strings.TrimPrefix(localePrefix(config.Site, locale)+"/", "/")
+	localized := config
+	if root != "" {
+		localized = localizedConfig(config, locale)
+	}
+
+	// The home page lists the pages through the API, so its response is exported as a file
+	pages := make([]pageResource, 0, len(localized.Pages))
+	for _, key := range sortedPageKeys(localized.Pages) {
+		pages = append(pages, newPageResource(key, localized.Pages[key]))
+	}
+	pagesJSON, err := json.Marshal(pages)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(outDir, root, "api"), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, root, "api", "pages"), pagesJSON, 0644); err != nil {
+		return err
+	}
+
+	// Render the standalone pages. Static hosts serve a single error page, so those only
+	// exist in the locale of the site.
+	standalonePages := []struct{ src, dst, path string }{
+		{"html/index.html", "index.html", "/"},
+		{"html/graph.html", "story/index.html", "/story"},
+		{"html/credits.html", "credits/index.html", "/credits"},
+		{"html/404.html", "404.html", ""},
+		{"html/500.html", "500.html", ""},
+	}
+	for _, page := range standalonePages {
+		if page.path == "" && root != "" {
+			continue
+		}
+
+		ts, err := template.ParseFS(uiFiles, page.src)
+		if err != nil {
+			return err
+		}
+
+		data := standaloneData{Site: config.Site, Credits: siteCredits(localized), localization: newLocalization(config.Site, locale)}
+		if page.path != "" {
+			data.localization = data.localization.withAlternates(config.Site, page.path)
+		}
+		// graph.json holds the story in the locale of the site, so a translated story is embedded
+		if root != "" {
+			translated := localizedStory(story, locale)
+			data.Story = &translated
+		}
+
+		var buf bytes.Buffer
+		if err := ts.Execute(&buf, data); err != nil {
+			return fmt.Errorf("rendering %s: %w", page.src, err)
+		}
+		// Static hosts serve 404.html at the missing URL, so its links cannot be relative to its file
+		if page.dst == "404.html" {
+			err = writeSitePageWithBase(outDir, root, page.dst, siteBasePath(config.Site), buf.Bytes())
+		} else {
+			err = writeSitePage(outDir, root, page.dst, buf.Bytes())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Render a page for each model
+	for _, key := range sortedPageKeys(config.Pages) {
+		var buf bytes.Buffer
+		if err := renderLocalizedPage(&buf, tmpl, config, story, key, layout, locale); err != nil {
+			return fmt.Errorf("rendering page %s: %w", key, err)
+		}
+		slug := pageSlug(key, config.Pages[key])
+		if err := writeSitePage(outDir, root, fmt.Sprintf("models/%s/index.html", slug), buf.Bytes()); err != nil {
+			return err
+		}
+		log.Printf("Built HTML for %s%s\n", root, key)
+	}
+	return nil
+}
+
+// siteBasePath returns the path the site is hosted under, from its BaseURL, ending with a slash
+func siteBasePath(site SiteConfig) string {
+	u, err := url.Parse(site.BaseURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return strings.TrimSuffix(u.Path, "/") + "/"
+}
+
+// redirectPage returns an HTML page sending the browser to the root-relative URL target
+func redirectPage(target string) []byte {
+	return []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <meta http-equiv="refresh" content="0; url=%s">
+    <link rel="canonical" href="%s">
+    <title>Redirecting...</title>
+</head>
+<body>
+    <a href="%s">Redirecting...</a>
+</body>
+</html>
+`, relativeLink(target), target, target))
+}
+
+// writeSitePage rewrites the links of an HTML page so they resolve relative to root, the
+// directory of its locale such as zh/ or the site root if empty, then writes it to rel inside root
+func writeSitePage(outDir string, root string, rel string, content []byte) error {
+	depth := strings.Count(rel, "/")
+	base := strings.Repeat("../", depth)
+	if base == "" {
+		base = "./"
+	}
+	return writeSitePageWithBase(outDir, root, rel, base, content)
+}
+
+// writeSitePageWithBase writes a page like writeSitePage, with base as the URL of the site root
+func writeSitePageWithBase(outDir string, root string, rel string, base string, content []byte) error {
+	html := linkAttrPattern.ReplaceAllStringFunc(string(content), func(match string) string {
+		parts := linkAttrPattern.FindStringSubmatch(match)
+		return parts[1] + rootedLink(parts[2], root) + parts[3]
+	})
+	// A <base> element makes links in scripts resolve against the root as well
+	html = strings.Replace(html, "<head>", fmt.Sprintf("<head>\n    <base href=\"%s\">", base), 1)
+
+	dst := filepath.Join(outDir, filepath.FromSlash(root+rel))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, []byte(html), 0644)
+}
+
+// relativeLink turns a root-relative URL into one relative to the site root, mapping
+// extensionless routes such as /story onto their story/ directory
+func relativeLink(link string) string {
+	if strings.HasPrefix(link, "//") {
+		return link // protocol-relative URL to another host
+	}
+
+	p, suffix := link, ""
+	if i := strings.IndexAny(link, "?#"); i >= 0 {
+		p, suffix = link[:i], link[i:]
+	}
+
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return "./" + suffix
+	}
+	if path.Ext(p) == "" && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p + suffix
+}
+
+// rootedLink turns a root-relative URL into one relative to root, the directory of a locale
+// such as zh/, or to the site root if root is empty
+func rootedLink(link string, root string) string {
+	rel := relativeLink(link)
+	if root == "" || strings.HasPrefix(rel, "//") {
+		return rel
+	}
+	if rest, ok := strings.CutPrefix(rel, root); ok {
+		if rest == "" || strings.ContainsAny(rest[:1], "?#") {
+			return "./" + rest
+		}
+		return rest
+	}
+	return strings.Repeat("../", strings.Count(root, "/")) + strings.TrimPrefix(rel, "./")
+}
+
+// checkLinks verifies that every internal link in the built HTML pages points to an existing file
+func checkLinks(outDir string) error {
+	hrefPattern := regexp.MustCompile(`\s(?:href|src|ios-src|poster|data-src|data-ios-src|data-poster|data-texture)=["']([^"']*)["']`)
+	basePattern := regexp.MustCompile(`<base href="([^"]*)">`)
+	var broken []string
+
+	err := filepath.Walk(outDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(file) != ".html" {
+			return err
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		// Links resolve against the <base> of the page, the root of its locale, which is not itself
+		// a link. An absolute base is the root of the site.
+		root := outDir
+		if match := basePattern.FindSubmatch(content); match != nil && !strings.HasPrefix(string(match[1]), "/") {
+			root = filepath.Join(filepath.Dir(file), filepath.FromSlash(string(match[1])))
+		}
+		content = basePattern.ReplaceAll(content, nil)
+		for _, match := range hrefPattern.FindAllStringSubmatch(string(content), -1) {
+			link := match[1]
+			if isExternalLink(link) {
+				continue
+			}
+			if i := strings.IndexAny(link, "?#"); i >= 0 {
+				link = link[:i]
+			}
+
+			target := filepath.Join(root, filepath.FromSlash(link))
+			if link == "" || strings.HasSuffix(link, "/") {
+				target = filepath.Join(target, "index.html")
+			}
+			if _, err := os.Stat(target); err != nil {
+				rel, _ := filepath.Rel(outDir, file)
+				broken = append(broken, fmt.Sprintf("%s: broken link %q", rel, match[1]))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(broken) > 0 {
+		for _, b := range broken {
+			log.Printf("%s%s%s", Red, b, Reset)
+		}
+		return fmt.Errorf("found %d broken internal link(s)", len(broken))
+	}
+	return nil
+}
+
+// isExternalLink reports whether a link points outside the built site or is not a file reference
+func isExternalLink(link string) bool {
+	return strings.HasPrefix(link, "//") ||
+		strings.HasPrefix(link, "#") ||
+		strings.Contains(strings.SplitN(link, "/", 2)[0], ":")
+}
+
+// copyDir recursively copies the directory root of fsys into dst
+func copyDir(fsys fs.FS, root string, dst string) error {
+	return fs.WalkDir(fsys, root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(strings.TrimPrefix(file, root)))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(fsys, file, target)
+	})
+}
+
+// copyFile copies a single file from fsys to dst
+func copyFile(fsys fs.FS, src string, dst string) error {
+	in, err := fsys.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRelativeLink(t *testing.T) {
+	tests := []struct {
+		link     string
+		expected string
+	}{
+		{"/", "./"},
+		{"/story", "story/"},
+		{"/model2", "model2/"},
+		{"/static/css/dim.css", "static/css/dim.css"},
+		{"/config.yaml", "config.yaml"},
+		{"/story?keyword=intro", "story/?keyword=intro"},
+		{"//cdn.example.com/lib.js", "//cdn.example.com/lib.js"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.link, func(t *testing.T) {
+			if got := relativeLink(test.link); got != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteSitePage(t *testing.T) {
+	outDir := t.TempDir()
+	content := `<html><head></head><body><a href="/story">Story</a><script src="/static/js/app.js"></script><button data-src="/static/models/b.glb"></button></body></html>`
+
+	if err := writeSitePage(outDir, "", "model1/index.html", []byte(content)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	written, err := os.ReadFile(filepath.Join(outDir, "model1", "index.html"))
+	if err != nil {
+		t.Fatalf("Expected page to be written, got %v", err)
+	}
+	for _, want := range []string{`<base href="../">`, `href="story/"`, `src="static/js/app.js"`, `data-src="static/models/b.glb"`} {
+		if !strings.Contains(string(written), want) {
+			t.Errorf("expected page to contain %s, got %s", want, written)
+		}
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	outDir := t.TempDir()
+	os.MkdirAll(filepath.Join(outDir, "static"), 0755)
+	os.WriteFile(filepath.Join(outDir, "static", "app.js"), []byte(""), 0644)
+
+	valid := `<html><head></head><body><a href="/">Home</a><a href="https://go.dev/">Go</a><script src="/static/app.js"></script></body></html>`
+	if err := writeSitePage(outDir, "", "index.html", []byte(valid)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := checkLinks(outDir); err != nil {
+		t.Fatalf("Expected no broken links, got %v", err)
+	}
+
+	broken := `<html><head></head><body><a href="/model9">Missing</a></body></html>`
+	if err := writeSitePage(outDir, "", "story/index.html", []byte(broken)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := checkLinks(outDir); err == nil {
+		t.Fatal("Expected broken link to be reported")
+	}
+}
+
+func TestBuildNotFoundPage(t *testing.T) {
+	useTestUIFiles(t)
+	for baseURL, base := range map[string]string{"": "/", "https://example.com": "/", "https://example.com/sack/": "/sack/"} {
+		outDir := t.TempDir()
+		config := Config{Site: SiteConfig{BaseURL: baseURL}, Pages: map[string]PageConfig{"page1": testPage("Model 1")}}
+		if err := buildSite(config, StoryGraph{}, parseTemplates(), "card", outDir); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		html, _ := os.ReadFile(filepath.Join(outDir, "404.html"))
+		for _, expected := range []string{`<base href="` + base + `">`, `href="static/css/errors.css"`} {
+			if !strings.Contains(string(html), expected) {
+				t.Errorf("Expected 404.html of %q to contain %s, got %s", baseURL, expected, html)
+			}
+		}
+	}
+}
+
+func TestCleanOutDir(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "dist")
+	if err := cleanOutDir(outDir, false); err != nil {
+		t.Fatalf("Expected a missing directory to be accepted, got %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(outDir, "models", "removed"), 0755)
+	os.WriteFile(filepath.Join(outDir, "models", "removed", "index.html"), []byte("stale"), 0644)
+	if err := cleanOutDir(outDir, false); err == nil {
+		t.Fatal("Expected a directory that is not empty to be refused")
+	}
+	if err := cleanOutDir(outDir, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "models")); !os.IsNotExist(err) {
+		t.Errorf("Expected files of the earlier build to be removed, got %v", err)
+	}
+
+	if err := cleanOutDir(".", true); err == nil {
+		t.Error("Expected the current directory to be kept")
+	}
+}
@@ -1,46 +1,88 @@
 package main
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
-	"log"
 	"os"
+	"regexp"
 )
 
 // PageConfig is a struct that holds the configuration for a page
 type PageConfig struct {
-	ModelSrcPath    string `yaml:"ModelSrcPath"`
-	ModelIosSrcPath string `yaml:"ModelIosSrcPath"`
-	PosterPath      string `yaml:"PosterPath"`
-	Description     string `yaml:"Description"`
-	ModelName       string `yaml:"ModelName"`
-	DesignerWebsite string `yaml:"DesignerWebsite"`
-	DesignerName    string `yaml:"DesignerName"`
+	ModelSrcPath    string           `yaml:"ModelSrcPath"`
+	ModelIosSrcPath string           `yaml:"ModelIosSrcPath"`
+	PosterPath      string           `yaml:"PosterPath"`
+	Description     string           `yaml:"Description"`
+	ModelName       string           `yaml:"ModelName"`
+	DesignerWebsite string           `yaml:"DesignerWebsite"`
+	DesignerName    string           `yaml:"DesignerName"`
+	Slug            string           `yaml:"Slug,omitempty"`
+	Order           int              `yaml:"Order,omitempty"`
+	Specs           []Spec           `yaml:"Specs,omitempty"`
+	Materials       []MaterialPreset `yaml:"Materials,omitempty"`
+	Annotations     []Annotation     `yaml:"Annotations,omitempty"`
+	Variants        []Variant        `yaml:"Variants,omitempty"`
+	VariantName     string           `yaml:"VariantName,omitempty"` // label of the page's own model among its variants
+	Layout          string           `yaml:"Layout,omitempty"`      // overrides the --layout of the site for this page
+	License         string           `yaml:"License,omitempty"`     // SPDX identifier such as CC-BY-4.0, defaults to the licence of the site
+	SourceURL       string           `yaml:"SourceURL,omitempty"`   // where the model or its scan comes from
+	Contributors    []Contributor    `yaml:"Contributors,omitempty"`
+	AcquiredOn      string           `yaml:"AcquiredOn,omitempty"` // date the object or scan was acquired, e.g. 2024-05-17
+
+	Translations map[string]PageTranslation `yaml:"Translations,omitempty"` // text of the page by locale, e.g. zh
 }
 
 type Config struct {
+	Site  SiteConfig            `yaml:"Site,omitempty"`
 	Pages map[string]PageConfig `yaml:"Pages"`
 }
 
-func writeConfig(filename string, config Config) {
+// slugPattern restricts slugs to characters that are safe in a URL path segment
+var slugPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// pageSlug returns the URL slug of a page, defaulting to its key in the config
+func pageSlug(key string, page PageConfig) string {
+	if page.Slug != "" {
+		return page.Slug
+	}
+	return key
+}
+
+// pageKeysBySlug maps the slug of every page to its key, rejecting invalid or duplicate slugs
+func pageKeysBySlug(pages map[string]PageConfig) (map[string]string, error) {
+	keys := make(map[string]string, len(pages))
+	for _, key := range sortedPageKeys(pages) {
+		slug := pageSlug(key, pages[key])
+		if !slugPattern.MatchString(slug) {
+			return nil, fmt.Errorf("page %s has an invalid slug %q", key, slug)
+		}
+		if other, ok := keys[slug]; ok {
+			return nil, fmt.Errorf("pages %s and %s share the slug %q", other, key, slug)
+		}
+		keys[slug] = key
+	}
+	return keys, nil
+}
+
+func writeConfig(filename string, config Config) error {
 	configData, err := yaml.Marshal(&config)
 	if err != nil {
-		log.Fatalf("Error marshalling config: %s", err)
+		return fmt.Errorf("marshalling config: %w", err)
 	}
 
 	file, err := os.OpenFile(filename, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer file.Close()
 
-	if _, err = file.Write(configData); err != nil {
-		log.Fatal(err)
-	}
+	_, err = file.Write(configData)
+	return err
 }
 
 func readConfig(filename string) (Config, error) {
 	var config Config
-	configData, err := os.ReadFile(filename)
+	configData, err := readConfigFile(filename)
 	if err != nil {
 		return config, err
 	}
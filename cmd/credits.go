@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Contributor is a person or institution credited for a model besides its designer,
+// e.g. the photographer of a scan
+type Contributor struct {
+	Name    string `yaml:"Name"`
+	Role    string `yaml:"Role,omitempty"`
+	Website string `yaml:"Website,omitempty"`
+}
+
+// licenseInfo describes the licence a model is shared under, as shown on its page
+type licenseInfo struct {
+	Name  string   // SPDX or Creative Commons identifier, or the URL of a licence set for the site
+	URL   string   // page of the licence
+	Icons []string // Creative Commons icons of the licence, if it is one
+}
+
+// ccLicensePattern matches Creative Commons identifiers in the SPDX form, e.g. CC-BY-SA-4.0
+var ccLicensePattern = regexp.MustCompile(`^CC-(BY|BY-SA|BY-ND|BY-NC|BY-NC-SA|BY-NC-ND)-(1\.0|2\.0|2\.5|3\.0|4\.0)$`)
+
+// spdxLicensePattern matches the characters allowed in SPDX license identifiers
+var spdxLicensePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+-]*$`)
+
+// acquisitionDateLayouts are the accepted precisions of AcquiredOn: a year, a month or a day
+var acquisitionDateLayouts = []string{"2006", "2006-01", "2006-01-02"}
+
+// licenseURL returns the page of a licence identifier: creativecommons.org for Creative
+// Commons licences and spdx.org for any other
+func licenseURL(id string) string {
+	switch {
+	case strings.EqualFold(id, "CC0-1.0"):
+		return "https://creativecommons.org/publicdomain/zero/1.0/"
+	case ccLicensePattern.MatchString(strings.ToUpper(id)):
+		match := ccLicensePattern.FindStringSubmatch(strings.ToUpper(id))
+		return fmt.Sprintf("https://creativecommons.org/licenses/%s/%s/", strings.ToLower(match[1]), match[2])
+	}
+	return fmt.Sprintf("https://spdx.org/licenses/%s.html", id)
+}
+
+// licenseIcons returns the Creative Commons icons of the licence at u, e.g. cc, by and sa for
+// https://creativecommons.org/licenses/by-sa/4.0/, or nil if it is not a Creative Commons licence
+func licenseIcons(u string) []string {
+	parsed, err := url.Parse(u)
+	if err != nil || !strings.HasSuffix(parsed.Host, "creativecommons.org") {
+		return nil
+	}
+
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "licenses":
+		return append([]string{"cc"}, strings.Split(parts[1], "-")...)
+	case len(parts) >= 2 && parts[0] == "publicdomain":
+		return []string{"zero"}
+	}
+	return nil
+}
+
+// pageLicense returns the licence of a page, falling back to the licence of the site
+func pageLicense(site SiteConfig, page PageConfig) licenseInfo {
+	if page.License != "" {
+		u := licenseURL(page.License)
+		return licenseInfo{Name: page.License, URL: u, Icons: licenseIcons(u)}
+	}
+	u := site.LicenseURL()
+	return licenseInfo{Name: u, URL: u, Icons: licenseIcons(u)}
+}
+
+// validateCredits checks the licence and attribution fields of a page, returning every invalid field
+func validateCredits(page PageConfig) []fieldError {
+	var errs []fieldError
+	if page.License != "" {
+		if strings.HasPrefix(strings.ToUpper(page.License), "CC-") && !ccLicensePattern.MatchString(strings.ToUpper(page.License)) {
+			errs = append(errs, fieldError{Field: "License", Message: fmt.Sprintf("%q is not a Creative Commons licence like CC-BY-4.0 or CC-BY-NC-SA-4.0", page.License)})
+		} else if !spdxLicensePattern.MatchString(page.License) {
+			errs = append(errs, fieldError{Field: "License", Message: fmt.Sprintf("%q must be an SPDX identifier like CC-BY-4.0, CC0-1.0 or MIT", page.License)})
+		}
+	}
+	if page.SourceURL != "" && !isHTTPURL(page.SourceURL) {
+		errs = append(errs, fieldError{Field: "SourceURL", Message: fmt.Sprintf("%q is not a valid http(s) URL", page.SourceURL)})
+	}
+	if page.AcquiredOn != "" && !isAcquisitionDate(page.AcquiredOn) {
+		errs = append(errs, fieldError{Field: "AcquiredOn", Message: fmt.Sprintf("%q must be a date like 2024, 2024-05 or 2024-05-17", page.AcquiredOn)})
+	}
+
+	for i, c := range page.Contributors {
+		field := func(name string) string { return fmt.Sprintf("Contributors[%d].%s", i, name) }
+		if strings.TrimSpace(c.Name) == "" {
+			errs = append(errs, fieldError{Field: field("Name"), Message: "is required"})
+		}
+		if c.Website != "" && !isHTTPURL(c.Website) {
+			errs = append(errs, fieldError{Field: field("Website"), Message: fmt.Sprintf("%q is not a valid http(s) URL", c.Website)})
+		}
+	}
+	return errs
+}
+
+// isHTTPURL reports whether s is an absolute http(s) URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isAcquisitionDate reports whether s is a year, month or day in ISO 8601 form
+func isAcquisitionDate(s string) bool {
+	for _, layout := range acquisitionDateLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// modelCredits is the attribution of one model listed on the credits page
+type modelCredits struct {
+	Slug string
+	PageConfig
+	License licenseInfo
+}
+
+// siteCredits returns the attribution of every model of the site in navigation order
+func siteCredits(config Config) []modelCredits {
+	var credits []modelCredits
+	for _, key := range sortedPageKeys(config.Pages) {
+		page := config.Pages[key]
+		credits = append(credits, modelCredits{Slug: pageSlug(key, page), PageConfig: page, License: pageLicense(config.Site, page)})
+	}
+	return credits
+}
+
+// credits handler for the page listing the attribution of every model
+func (s *site) credits(w http.ResponseWriter, r *http.Request) {
+	config := s.state.Load().config
+	locale := requestLocale(r, config.Site)
+
+	ts, err := standaloneTemplate("credits.html")
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	setLocaleHeaders(w, locale)
+	localization := newLocalization(config.Site, locale).withAlternates(config.Site, "/credits")
+	err = ts.Execute(w, standaloneData{Site: config.Site, Credits: siteCredits(localizedConfig(config, locale)), localization: localization})
+	if err != nil {
+		serverError(w, r, err)
+	}
+}
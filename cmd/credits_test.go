@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLicenseURL(t *testing.T) {
+	tests := map[string]string{
+		"CC-BY-4.0":       "https://creativecommons.org/licenses/by/4.0/",
+		"cc-by-nc-sa-3.0": "https://creativecommons.org/licenses/by-nc-sa/3.0/",
+		"CC0-1.0":         "https://creativecommons.org/publicdomain/zero/1.0/",
+		"MIT":             "https://spdx.org/licenses/MIT.html",
+	}
+	for id, expected := range tests {
+		if u := licenseURL(id); u != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, id, u)
+		}
+	}
+}
+
+func TestLicenseIcons(t *testing.T) {
+	tests := []struct {
+		license  string
+		expected []string
+	}{
+		{"", []string{"cc", "by"}},
+		{"https://creativecommons.org/licenses/by-nc-sa/4.0/", []string{"cc", "by", "nc", "sa"}},
+		{"https://creativecommons.org/publicdomain/zero/1.0/", []string{"zero"}},
+		{"https://opensource.org/license/mit", nil},
+	}
+	for _, tt := range tests {
+		if icons := licenseIcons((SiteConfig{License: tt.license}).LicenseURL()); !reflect.DeepEqual(icons, tt.expected) {
+			t.Errorf("Expected icons %v for %q, got %v", tt.expected, tt.license, icons)
+		}
+	}
+}
+
+func TestValidateCredits(t *testing.T) {
+	valid := PageConfig{
+		License:      "CC-BY-SA-4.0",
+		SourceURL:    "https://example.com/scans/1",
+		Contributors: []Contributor{{Name: "A. Scanner", Role: "Photogrammetry", Website: "https://example.com"}},
+		AcquiredOn:   "2024-05",
+	}
+	if errs := validateCredits(valid); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	invalid := PageConfig{
+		License:      "CC-BY-XY-4.0",
+		SourceURL:    "example.com",
+		Contributors: []Contributor{{Role: "Photographer", Website: "ftp://example.com"}},
+		AcquiredOn:   "17/05/2024",
+	}
+	var fields []string
+	for _, fe := range validateCredits(invalid) {
+		fields = append(fields, fe.Field)
+	}
+	expected := []string{"License", "SourceURL", "AcquiredOn", "Contributors[0].Name", "Contributors[0].Website"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Expected errors for %v, got %v", expected, fields)
+	}
+
+	if errs := validateCredits(PageConfig{License: "MIT OR Apache"}); len(errs) != 1 {
+		t.Errorf("Expected an SPDX identifier with spaces to be rejected, got %v", errs)
+	}
+}
+
+func TestRenderCredits(t *testing.T) {
+	credited := testPage("Model 1")
+	credited.License = "CC-BY-NC-4.0"
+	credited.SourceURL = "https://example.com/scans/1"
+	credited.Contributors = []Contributor{{Name: "A. Scanner", Role: "Photogrammetry"}, {Name: "Museum", Website: "https://museum.example.com"}}
+	config := Config{Pages: map[string]PageConfig{"page1": credited, "page2": testPage("Model 2")}}
+
+	for _, layout := range []string{"card", "plain"} {
+		var buf strings.Builder
+		if err := renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page1", layout); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		html := buf.String()
+		for _, expected := range []string{
+			`<script type="application/ld+json">`,
+			`<a href="https://example.com/scans/1" target="_blank">`,
+			`A. Scanner (Photogrammetry), <a href="https://museum.example.com" target="_blank">Museum</a>`,
+			`href="https://creativecommons.org/licenses/by-nc/4.0/"`,
+		} {
+			if !strings.Contains(html, expected) {
+				t.Errorf("Expected %s layout to contain %s, got %s", layout, expected, html)
+			}
+		}
+
+		buf.Reset()
+		renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page2", layout)
+		if html := buf.String(); strings.Contains(html, `class="model-credits"`) {
+			t.Errorf("Expected %s layout to leave out the credits of a page without any", layout)
+		}
+	}
+}
+
+func TestCreditsPage(t *testing.T) {
+	credited := testPage("Model 1")
+	credited.DesignerName = "Designer"
+	credited.License = "MIT"
+	server, _ := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": credited, "page2": testPage("Model 2")}})
+
+	resp, body := doJSON(t, "GET", server.URL+"/credits", "", "")
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	for _, expected := range []string{
+		`<a href="/models/page1">Model 1</a>`,
+		`<a href="/models/page2">Model 2</a>`,
+		`<a href="https://spdx.org/licenses/MIT.html" target="_blank" rel="license">MIT</a>`,
+		`<a href="https://creativecommons.org/licenses/by/2.0/" target="_blank" rel="license">`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the credits page to contain %s, got %s", expected, body)
+		}
+	}
+	if strings.Index(body, "Model 1") > strings.Index(body, "Model 2") {
+		t.Errorf("Expected the models in navigation order")
+	}
+}
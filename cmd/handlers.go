@@ -1,7 +1,7 @@
 package main
 
 import (
-	"html/template"
+	"fmt"
 	"log"
 	"net/http"
 )
@@ -9,68 +9,107 @@ import (
 // home handler for the home page
 func home(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
-		notFound(w)
+		notFound(w, r)
 		return
 	}
 
-	ts, err := template.ParseFiles("./ui/html/index.html")
+	ts, err := standaloneTemplate("index.html")
 	if err != nil {
-		serverError(w, err)
+		serverError(w, r, err)
 		return
 	}
 
-	err = ts.Execute(w, nil)
+	site := siteSettings()
+	locale := requestLocale(r, site)
+	setLocaleHeaders(w, locale)
+	err = ts.Execute(w, standaloneData{Site: site, Search: true, localization: newLocalization(site, locale).withAlternates(site, "/")})
 	if err != nil {
-		serverError(w, err)
+		serverError(w, r, err)
 	}
 }
 
-// graph handler for the graph page
+// graph handler for the graph page. With a keyword, only the part of the story around the
+// matching nodes is embedded in the page, so a chapter can be linked to directly. In another
+// locale than the site's, the translated story is embedded as well.
 func graph(w http.ResponseWriter, r *http.Request) {
-	// Check for query parameters (optional)
-	if keyword := r.URL.Query().Get("keyword"); keyword != "" {
-		log.Printf("Keyword parameter: %s", keyword)
-		// Here you could use the keyword to filter or customize the response
+	site := siteSettings()
+	locale := requestLocale(r, site)
+	keyword := r.URL.Query().Get("keyword")
+
+	var embedded *StoryGraph
+	if keyword != "" || locale != site.SiteLocale() {
+		story, err := readStoryGraph(storyGraphPath)
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+		story = localizedStory(story, locale)
+
+		if keyword != "" {
+			depth, err := parseStoryDepth(r.URL.Query().Get("depth"))
+			if err != nil {
+				depth = defaultStoryDepth
+			}
+			subgraph := filterStoryGraph(story, keyword, depth)
+			log.Printf("Keyword %q matched %d of %d story nodes", keyword, len(subgraph.Nodes), len(story.Nodes))
+			story = subgraph
+		}
+		embedded = &story
 	}
 
-	ts, err := template.ParseFiles("./ui/html/graph.html")
+	ts, err := standaloneTemplate("graph.html")
 	if err != nil {
-		serverError(w, err)
+		serverError(w, r, err)
 		return
 	}
 
-	err = ts.Execute(w, nil)
+	setLocaleHeaders(w, locale)
+	err = ts.Execute(w, standaloneData{Site: site, Story: embedded, Search: true, localization: newLocalization(site, locale).withAlternates(site, "/story")})
 	if err != nil {
-		serverError(w, err)
+		serverError(w, r, err)
 	}
 }
 
 // notFound handler for custom 404 page
-func notFound(w http.ResponseWriter) {
-	ts, err := template.ParseFiles("./ui/html/404.html")
+func notFound(w http.ResponseWriter, r *http.Request) {
+	ts, err := standaloneTemplate("404.html")
 	if err != nil {
-		serverError(w, err)
+		serverError(w, r, err)
 		return
 	}
 
+	site := siteSettings()
+	data := standaloneData{Site: site, localization: newLocalization(site, requestLocale(r, site))}
+	if serverMode == devMode {
+		data.Detail = fmt.Sprintf("No route or page matches %s %s", r.Method, r.URL.RequestURI())
+	}
 	w.WriteHeader(http.StatusNotFound)
-	err = ts.Execute(w, nil)
+	err = ts.Execute(w, data)
 	if err != nil {
-		serverError(w, err)
+		serverError(w, r, err)
 	}
 }
 
-// serverError handler for custom 500 page
-func serverError(w http.ResponseWriter, err error) {
-	log.Print(err.Error())
-	ts, err := template.ParseFiles("./ui/html/500.html")
+// serverError handler for custom 500 page, showing the error itself in dev mode
+func serverError(w http.ResponseWriter, r *http.Request, cause error) {
+	log.Print(cause.Error())
+	ts, err := standaloneTemplate("500.html")
 	if err != nil {
+		if serverMode == devMode {
+			http.Error(w, fmt.Sprintf("%s\n\nwhile rendering the error page: %s", cause, err), http.StatusInternalServerError)
+			return
+		}
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	site := siteSettings()
+	data := standaloneData{Site: site, localization: newLocalization(site, requestLocale(r, site))}
+	if serverMode == devMode {
+		data.Detail = fmt.Sprintf("%s %s\n\n%s", r.Method, r.URL.RequestURI(), cause)
+	}
 	w.WriteHeader(http.StatusInternalServerError)
-	err = ts.Execute(w, nil)
+	err = ts.Execute(w, data)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"text/template"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -27,95 +32,240 @@ func startServer(mux http.Handler, port int) {
 
 // parseTemplates loads and parses HTML templates, adding custom template functions
 func parseTemplates() *template.Template {
+	return template.Must(loadTemplates())
+}
+
+// loadTemplates parses the page templates and every layout, returning an error instead of panicking
+func loadTemplates() (*template.Template, error) {
+	layouts, err := readLayouts()
+	if err != nil {
+		return nil, err
+	}
+
+	var tmpl *template.Template
 	funcMap := template.FuncMap{
 		"add": func(i int) int { return i + 1 },
 		"sub": func(i int) int { return i - 1 },
+		// layout renders the template of the named layout, which cannot be named by a constant
+		"layout": func(name string, data any) (template.HTML, error) {
+			var buf strings.Builder
+			err := tmpl.ExecuteTemplate(&buf, name, data)
+			return template.HTML(buf.String()), err
+		},
+		"stylesheets": func(name string) []string { return layouts[name].Stylesheets },
+		"scripts":     func(name string) []string { return layouts[name].Scripts },
 	}
-	return template.Must(template.New("base").Funcs(funcMap).ParseGlob("ui/html/templates/*.gohtml"))
+	tmpl, err = template.New("base").Funcs(funcMap).ParseFS(uiFiles, "html/templates/*.gohtml")
+	if err != nil {
+		return nil, err
+	}
+
+	// Each layout directory defines a template named after the layout
+	for _, name := range layoutNames() {
+		if _, err := tmpl.ParseFS(uiFiles, path.Join(layoutsDir, name, "*.gohtml")); err != nil {
+			return nil, fmt.Errorf("layout %s: %w", name, err)
+		}
+		if tmpl.Lookup(name) == nil {
+			return nil, fmt.Errorf("layout %s does not define a template named %q", name, name)
+		}
+	}
+	return tmpl, nil
 }
 
 // setupHandlers configures and returns an HTTP ServeMux with all route handlers
-func setupHandlers(config Config) *http.ServeMux {
+func setupHandlers(s *site) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Serve static files
-	fileServer := http.FileServer(http.Dir("./ui/static"))
+	staticFiles, err := fs.Sub(uiFiles, "static")
+	if err != nil {
+		log.Fatalf("Error opening static files: %s", err)
+	}
+	fileServer := http.FileServer(http.FS(staticFiles))
 	mux.Handle("/static/", http.StripPrefix("/static", fileServer))
 
 	// Serve configuration files
-	mux.HandleFunc("/config.yaml", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, configPath)
-	})
-	mux.HandleFunc("/graph.json", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, storyGraphPath)
-	})
+	mux.HandleFunc("/config.yaml", serveConfigFile(configPath))
+	mux.HandleFunc("/graph.json", serveConfigFile(storyGraphPath))
 
-	// Set up handlers for each page
-	for i := 1; i <= len(config.Pages); i++ {
-		pageFilename := fmt.Sprintf("./ui/html/pages/page%d.gohtml", i)
-		mux.HandleFunc("/model"+strconv.Itoa(i), func(w http.ResponseWriter, r *http.Request) {
-			http.ServeFile(w, r, pageFilename)
-		})
-	}
+	// Set up handlers for each page, redirecting the legacy numbered routes
+	mux.HandleFunc("/models/{slug}", s.servePage)
+	mux.HandleFunc("/{legacy}", s.serveLegacyPage)
+
+	// Set up the JSON API
+	setupAPI(mux, s)
 
 	// Set up main route handlers
 	mux.HandleFunc("/story", graph)
+	mux.HandleFunc("/credits", s.credits)
+	mux.HandleFunc("/sitemap.xml", s.sitemap)
+	mux.HandleFunc("/robots.txt", s.robots)
 	mux.HandleFunc("/", home)
 
+	// Serve every route in another locale under its prefix, e.g. /zh/models/page1
+	mux.HandleFunc("/{locale}/", serveLocalized(mux))
+
 	return mux
 }
 
-// generateHTMLFiles creates individual HTML files for each page based on the configuration
-func generateHTMLFiles(config Config, tmpl *template.Template, layout string) {
-	dir := "./ui/html/pages"
+// pageData holds the values passed to the base template when rendering a page
+type pageData struct {
+	localization
+	CurrentPage int
+	TotalPages  int
+	PrevSlug    string
+	NextSlug    string
+	PageConfig  PageConfig
+	Layout      string
+	Story       []storyAppearance
+	Site        SiteConfig
+	URL         string // public URL of the page in its locale, if the site has a BaseURL
+	Image       string // public URL of the poster, if the site has a BaseURL
+	License     licenseInfo
+	JSONLD      template.JS // schema.org description of the model
+}
+
+// renderPage executes the base template for the page stored under key
+func renderPage(w io.Writer, tmpl *template.Template, config Config, story StoryGraph, key string, layout string) error {
+	return renderLocalizedPage(w, tmpl, config, story, key, layout, config.Site.SiteLocale())
+}
+
+// renderLocalizedPage executes the base template for the page stored under key, with its
+// text and the story translated into locale
+func renderLocalizedPage(w io.Writer, tmpl *template.Template, config Config, story StoryGraph, key string, layout string, locale string) error {
+	if locale != config.Site.SiteLocale() {
+		config = localizedConfig(config, locale)
+		story = localizedStory(story, locale)
+	}
+
 	keys := sortedPageKeys(config.Pages)
-	for _, key := range keys {
-		pageConfig := config.Pages[key]
-		pageNumber, _ := extractNumber(key)
-		pageFilename := fmt.Sprintf("%s/page%d.gohtml", dir, pageNumber)
+	page := config.Pages[key]
+	if page.Layout != "" {
+		layout = page.Layout
+	}
+	p := "/models/" + pageSlug(key, page)
+	data := pageData{
+		localization: newLocalization(config.Site, locale).withAlternates(config.Site, p),
+		TotalPages:   len(keys),
+		PageConfig:   page,
+		Layout:       layout,
+		Story:        pageStory(config, story, key),
+		Site:         config.Site,
+		URL:          config.Site.AbsoluteURL(localePrefix(config.Site, locale) + p),
+		Image:        config.Site.AbsoluteURL(page.PosterPath),
+	}
 
-		newPage, err := os.Create(pageFilename)
-		if err != nil {
-			log.Fatalf("Error creating page file for %s: %s", key, err)
-		}
-		defer newPage.Close()
-
-		err = tmpl.ExecuteTemplate(newPage, "base", struct {
-			CurrentPage int
-			TotalPages  int
-			PageConfig  PageConfig
-			Layout      string
-		}{
-			CurrentPage: pageNumber,
-			TotalPages:  len(config.Pages),
-			PageConfig:  pageConfig,
-			Layout:      layout,
-		})
+	// Link to the neighbouring pages in navigation order
+	for i, k := range keys {
+		if k != key {
+			continue
+		}
+		data.CurrentPage = i + 1
+		if i > 0 {
+			data.PrevSlug = pageSlug(keys[i-1], config.Pages[keys[i-1]])
+		}
+		if i < len(keys)-1 {
+			data.NextSlug = pageSlug(keys[i+1], config.Pages[keys[i+1]])
+		}
+	}
+	if data.CurrentPage == 0 {
+		return fmt.Errorf("page %s not found", key)
+	}
+
+	data.License = pageLicense(config.Site, page)
+	jsonLD, err := pageJSONLD(config.Site, key, page)
+	if err != nil {
+		return err
+	}
+	data.JSONLD = template.JS(jsonLD)
+
+	return tmpl.ExecuteTemplate(w, "base", data)
+}
+
+// serveConfigFile returns a handler serving a configuration file, or its embedded sample
+func serveConfigFile(filename string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := readConfigFile(filename)
 		if err != nil {
-			log.Fatalf("Error executing template for page %s: %s", key, err)
+			notFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(filename), time.Time{}, bytes.NewReader(data))
+	}
+}
+
+// generateHTMLFiles creates individual HTML files for each page based on the configuration
+func generateHTMLFiles(config Config, story StoryGraph, tmpl *template.Template, layout string) {
+	if err := writeHTMLFiles(config, story, tmpl, layout); err != nil {
+		log.Fatalf("Error generating HTML files: %s", err)
+	}
+}
+
+// writeHTMLFiles renders every page into its own file, returning the first error encountered
+func writeHTMLFiles(config Config, story StoryGraph, tmpl *template.Template, layout string) error {
+	dir := "./ui/html/pages"
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating pages directory: %w", err)
+	}
+
+	for _, key := range sortedPageKeys(config.Pages) {
+		var buf bytes.Buffer
+		if err := renderPage(&buf, tmpl, config, story, key, layout); err != nil {
+			return fmt.Errorf("executing template for page %s: %w", key, err)
+		}
+
+		pageFilename := fmt.Sprintf("%s/%s.gohtml", dir, pageSlug(key, config.Pages[key]))
+		if err := os.WriteFile(pageFilename, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("creating page file for %s: %w", key, err)
 		}
 		log.Printf("Generated HTML for %s\n", key)
 	}
+	return nil
 }
 
-// sortedPageKeys returns a sorted list of page keys from the configuration
+// sortedPageKeys returns the page keys in navigation order: pages with an explicit Order
+// come first, followed by the rest sorted by the number in their key, then by name
 func sortedPageKeys(pages map[string]PageConfig) []string {
-	keys := make([]int, 0, len(pages))
-	keyMap := make(map[int]string)
+	keys := make([]string, 0, len(pages))
 	for key := range pages {
-		pageNumber, err := extractNumber(key)
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := pages[keys[i]], pages[keys[j]]
+		if (a.Order != 0) != (b.Order != 0) {
+			return a.Order != 0
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+
+		numA, errA := extractNumber(keys[i])
+		numB, errB := extractNumber(keys[j])
+		if (errA == nil) != (errB == nil) {
+			return errA == nil
+		}
+		if numA != numB {
+			return numA < numB
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// legacyPageNumbers maps the numbers of the former /modelN routes to their page keys
+func legacyPageNumbers(pages map[string]PageConfig) map[int]string {
+	numbers := make(map[int]string)
+	for _, key := range sortedPageKeys(pages) {
+		number, err := extractNumber(key)
 		if err != nil {
-			log.Fatalf("Error: Key '%s' does not contain a number", key)
+			continue
+		}
+		if _, ok := numbers[number]; !ok {
+			numbers[number] = key
 		}
-		keys = append(keys, pageNumber)
-		keyMap[pageNumber] = key
-	}
-	sort.Ints(keys)
-	sortedKeys := make([]string, len(keys))
-	for i, num := range keys {
-		sortedKeys[i] = keyMap[num]
 	}
-	return sortedKeys
+	return numbers
 }
 
 // extractNumber retrieves the numeric part from a string key
@@ -128,22 +278,27 @@ func extractNumber(key string) (int, error) {
 	return strconv.Atoi(numStr)
 }
 
-// add paths recursively to the fsnotify watcher
-func addPathsRecursively(watcher *fsnotify.Watcher, root string) error {
+// add paths recursively to the fsnotify watcher, skipping the directories ignore excludes
+// and reading the ignore files of the others on the way
+func addPathsRecursively(watcher *fsnotify.Watcher, root string, ignore *ignoreMatcher) error {
 	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			log.Printf("%sError accessing path %s: %v%s", Red, path, err, Reset)
 			return err
 		}
 
-		// Ignore hidden files and directories
-		if strings.HasPrefix(info.Name(), ".") {
-			log.Printf("%sIgnoring hidden file or directory: %s%s", Yellow, path, Reset)
+		// Only the directories below root can be ignored, root was asked for explicitly
+		if path != root && ignore.Ignored(path, info.IsDir()) {
+			if info.IsDir() {
+				log.Printf("%sIgnoring directory: %s%s", Yellow, path, Reset)
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
 		// Add only directories to the watcher
 		if info.IsDir() {
+			ignore.load(path)
 			err = watcher.Add(path)
 			if err != nil {
 				log.Printf("%sError watching path %s: %v%s", Red, path, err, Reset)
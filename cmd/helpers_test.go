@@ -1,12 +1,12 @@
 package main
 
 import (
+	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
-	"text/template"
 )
 
 func TestStartServer(t *testing.T) {
@@ -51,7 +51,7 @@ func TestGenerateHTMLFiles(t *testing.T) {
 	tmpl := template.Must(template.New("base").Parse("Page: {{.PageConfig.ModelName}}"))
 
 	// Generate HTML files
-	generateHTMLFiles(config, tmpl, "card")
+	generateHTMLFiles(config, StoryGraph{}, tmpl, "card")
 
 	// Check if the file is created
 	pageFilename := filepath.Join(dir, "page1.gohtml")
@@ -138,7 +138,11 @@ func TestSetupHandlers(t *testing.T) {
 		},
 	}
 
-	mux := setupHandlers(config)
+	s, err := newSite(config, StoryGraph{}, nil, "card", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	mux := setupHandlers(s)
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
@@ -150,3 +154,74 @@ func TestSetupHandlers(t *testing.T) {
 		t.Fatalf("Expected status OK, got %v", resp.Status)
 	}
 }
+
+func TestSortedPageKeysWithOrder(t *testing.T) {
+	pages := map[string]PageConfig{
+		"page1":   {},
+		"page2":   {Order: 2},
+		"intro":   {Order: 1},
+		"page10":  {},
+		"outtake": {},
+	}
+
+	expected := []string{"intro", "page2", "page1", "page10", "outtake"}
+	sortedKeys := sortedPageKeys(pages)
+
+	for i, key := range sortedKeys {
+		if key != expected[i] {
+			t.Errorf("expected key %s at index %d, got %s", expected[i], i, key)
+		}
+	}
+}
+
+func TestPageKeysBySlug(t *testing.T) {
+	slugs, err := pageKeysBySlug(map[string]PageConfig{
+		"page1": {Slug: "dawanshiju"},
+		"page2": {},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if slugs["dawanshiju"] != "page1" || slugs["page2"] != "page2" {
+		t.Fatalf("Unexpected slug mapping: %v", slugs)
+	}
+
+	if _, err := pageKeysBySlug(map[string]PageConfig{"page1": {Slug: "page2"}, "page2": {}}); err == nil {
+		t.Fatal("Expected duplicate slugs to be rejected")
+	}
+	if _, err := pageKeysBySlug(map[string]PageConfig{"page1": {Slug: "a/b"}}); err == nil {
+		t.Fatal("Expected invalid slug to be rejected")
+	}
+}
+
+func TestLegacyRedirect(t *testing.T) {
+	config := Config{
+		Pages: map[string]PageConfig{
+			"page1": {Slug: "dawanshiju"},
+		},
+	}
+
+	s, err := newSite(config, StoryGraph{}, nil, "card", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	mux := setupHandlers(s)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(server.URL + "/model1?variant=raw")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("Expected status 301, got %v", resp.Status)
+	}
+	if location := resp.Header.Get("Location"); location != "/models/dawanshiju?variant=raw" {
+		t.Fatalf("Expected redirect to /models/dawanshiju?variant=raw, got %s", location)
+	}
+}
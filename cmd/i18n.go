@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// i18nDir holds one message catalog per locale, named after it, e.g. i18n/zh.yaml
+const i18nDir = "i18n"
+
+// fallbackLocale is the locale of the catalog defining every message, used for the ones
+// missing from other catalogs
+const fallbackLocale = "en"
+
+// localeKey is the context key of the locale given by the URL prefix of a request
+type localeKey struct{}
+
+// PageTranslation holds the text of a page in another locale, replacing the fields that are set
+type PageTranslation struct {
+	ModelName   string `yaml:"ModelName,omitempty"`
+	Description string `yaml:"Description,omitempty"`
+}
+
+// StoryTranslation holds the text of a story node in another locale, replacing the fields that are set
+type StoryTranslation struct {
+	Keyword string `json:"keyword,omitempty"`
+	Story   string `json:"story,omitempty"`
+}
+
+// alternateLink points to the version of a page in another locale
+type alternateLink struct {
+	Locale string
+	Name   string // name of the language in itself, e.g. 中文
+	Path   string // root-relative path of the version
+	URL    string // public URL of the version, or its path if the site has no BaseURL
+}
+
+// localization is the locale a page is rendered in, with its messages and the other versions of the page
+type localization struct {
+	Locale     string
+	Prefix     string // path prefix of the locale, empty for the locale of the site
+	Messages   map[string]string
+	Alternates []alternateLink
+	DefaultURL string // version in the locale of the site, for the visitors of no other locale
+}
+
+// T returns the message of key in the locale, formatted with args if any, or key itself if
+// no catalog defines it
+func (l localization) T(key string, args ...any) string {
+	message, ok := l.Messages[key]
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// readCatalog reads the messages of a locale from ui/i18n
+func readCatalog(locale string) (map[string]string, error) {
+	data, err := fs.ReadFile(uiFiles, path.Join(i18nDir, locale+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var messages map[string]string
+	if err := yaml.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("parsing catalog %s: %w", locale, err)
+	}
+	return messages, nil
+}
+
+// catalogSet holds the message catalogs of ui/i18n, read once and shared by every request
+type catalogSet struct {
+	locales  []string                     // locales with a catalog, in alphabetical order
+	messages map[string]map[string]string // messages of each locale, completed with the English ones
+	names    map[string]string            // name of the language of each locale in itself
+}
+
+// catalogs holds the loaded catalogs, or nil until they are first needed or after dropCatalogs
+var catalogs atomic.Pointer[catalogSet]
+
+// loadCatalogs reads every catalog under ui/i18n
+func loadCatalogs() *catalogSet {
+	set := &catalogSet{messages: make(map[string]map[string]string), names: make(map[string]string)}
+	fallback, err := readCatalog(fallbackLocale)
+	if err != nil {
+		fallback = make(map[string]string)
+	}
+
+	entries, err := fs.ReadDir(uiFiles, i18nDir)
+	if err != nil {
+		set.locales = []string{fallbackLocale}
+	}
+	for _, entry := range entries {
+		if locale, ok := strings.CutSuffix(entry.Name(), ".yaml"); ok && !entry.IsDir() {
+			set.locales = append(set.locales, locale)
+		}
+	}
+	sort.Strings(set.locales)
+
+	for _, locale := range set.locales {
+		messages := make(map[string]string, len(fallback))
+		for key, message := range fallback {
+			messages[key] = message
+		}
+		translated, err := readCatalog(locale)
+		if err != nil {
+			log.Printf("%sError reading catalog %s: %s%s", Red, locale, err, Reset)
+		}
+		for key, message := range translated {
+			messages[key] = message
+		}
+		set.messages[locale] = messages
+		set.names[locale] = translated["language.name"]
+	}
+	return set
+}
+
+// loadedCatalogs returns the catalogs, reading them if they are not loaded yet
+func loadedCatalogs() *catalogSet {
+	if set := catalogs.Load(); set != nil {
+		return set
+	}
+	set := loadCatalogs()
+	catalogs.Store(set)
+	return set
+}
+
+// dropCatalogs makes the next request read the catalogs again, after one of them changed
+func dropCatalogs() {
+	catalogs.Store(nil)
+}
+
+// messagesFor returns the messages of a locale, falling back to the English ones. The map is
+// shared and must not be modified.
+func messagesFor(locale string) map[string]string {
+	if messages, ok := loadedCatalogs().messages[locale]; ok {
+		return messages
+	}
+	return loadedCatalogs().messages[fallbackLocale]
+}
+
+// languageName returns the name of the language of a locale in itself, as set by its catalog
+func languageName(locale string) string {
+	if name := loadedCatalogs().names[locale]; name != "" {
+		return name
+	}
+	return locale
+}
+
+// availableLocales returns the locales with a catalog under ui/i18n in alphabetical order
+func availableLocales() []string {
+	return loadedCatalogs().locales
+}
+
+// isAvailableLocale reports whether locale has a message catalog
+func isAvailableLocale(locale string) bool {
+	for _, l := range availableLocales() {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLocale returns why locale cannot be used, or "" if it can
+func checkLocale(locale string) string {
+	if isAvailableLocale(locale) {
+		return ""
+	}
+	return fmt.Sprintf("%q has no message catalog under ui/%s (available: %s)", locale, i18nDir, strings.Join(availableLocales(), ", "))
+}
+
+// SiteLocale returns the locale the text of the config and the unprefixed pages are in
+func (s SiteConfig) SiteLocale() string {
+	if s.Locale != "" {
+		return s.Locale
+	}
+	return fallbackLocale
+}
+
+// localePrefix returns the path prefix of the pages in locale
+func localePrefix(site SiteConfig, locale string) string {
+	if locale == site.SiteLocale() {
+		return ""
+	}
+	return "/" + locale
+}
+
+// newLocalization returns the localization of a page in locale
+func newLocalization(site SiteConfig, locale string) localization {
+	return localization{Locale: locale, Prefix: localePrefix(site, locale), Messages: messagesFor(locale)}
+}
+
+// withAlternates links the localization of the page at the root-relative path p to the
+// versions of the page in every locale of the site
+func (l localization) withAlternates(site SiteConfig, p string) localization {
+	locales := availableLocales()
+	if len(locales) < 2 {
+		return l
+	}
+	for _, other := range locales {
+		localized := localePrefix(site, other) + p
+		u := site.AbsoluteURL(localized)
+		if u == "" {
+			u = localized
+		}
+		l.Alternates = append(l.Alternates, alternateLink{Locale: other, Name: languageName(other), Path: localized, URL: u})
+		if other == site.SiteLocale() {
+			l.DefaultURL = u
+		}
+	}
+	return l
+}
+
+// requestLocale returns the locale to answer r in: the one of its URL prefix, of its ?lang=
+// parameter, or the best match of its Accept-Language header, defaulting to the site's
+func requestLocale(r *http.Request, site SiteConfig) string {
+	if locale, ok := r.Context().Value(localeKey{}).(string); ok {
+		return locale
+	}
+	if lang := r.URL.Query().Get("lang"); lang != "" && isAvailableLocale(lang) {
+		return lang
+	}
+	if locale := acceptedLocale(r.Header.Get("Accept-Language"), availableLocales()); locale != "" {
+		return locale
+	}
+	return site.SiteLocale()
+}
+
+// acceptedLocale returns the available locale preferred by an Accept-Language header, matching
+// either the whole language tag or its primary language, or "" if none is acceptable
+func acceptedLocale(header string, locales []string) string {
+	type preference struct {
+		tag string
+		q   float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			preferences = append(preferences, preference{tag, q})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].q > preferences[j].q })
+
+	for _, p := range preferences {
+		primary, _, _ := strings.Cut(p.tag, "-")
+		for _, candidate := range []string{p.tag, primary} {
+			for _, locale := range locales {
+				if strings.EqualFold(locale, candidate) {
+					return locale
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// serveLocalized returns a handler serving the paths prefixed by a locale, e.g. /zh/models/page1,
+// as the unprefixed path answered in that locale
+func serveLocalized(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locale := r.PathValue("locale")
+		if _, nested := r.Context().Value(localeKey{}).(string); nested || !isAvailableLocale(locale) {
+			notFound(w, r)
+			return
+		}
+
+		localized := r.Clone(context.WithValue(r.Context(), localeKey{}, locale))
+		localized.URL.Path = strings.TrimPrefix(r.URL.Path, "/"+locale)
+		localized.URL.RawPath = ""
+		mux.ServeHTTP(w, localized)
+	}
+}
+
+// setLocaleHeaders tells caches that a response depends on the locale of the request
+func setLocaleHeaders(w http.ResponseWriter, locale string) {
+	w.Header().Set("Content-Language", locale)
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// Localized returns the page with its text in locale wherever it has a translation
+func (p PageConfig) Localized(locale string) PageConfig {
+	t, ok := p.Translations[locale]
+	if !ok {
+		return p
+	}
+	if t.ModelName != "" {
+		p.ModelName = t.ModelName
+	}
+	if t.Description != "" {
+		p.Description = t.Description
+	}
+	return p
+}
+
+// Localized returns the node with its text in locale wherever it has a translation
+func (n StoryNode) Localized(locale string) StoryNode {
+	t, ok := n.Translations[locale]
+	if !ok {
+		return n
+	}
+	if t.Keyword != "" {
+		n.Keyword = t.Keyword
+	}
+	if t.Story != "" {
+		n.Story = t.Story
+	}
+	return n
+}
+
+// localizedConfig returns config with every page in locale
+func localizedConfig(config Config, locale string) Config {
+	pages := make(map[string]PageConfig, len(config.Pages))
+	for key, page := range config.Pages {
+		pages[key] = page.Localized(locale)
+	}
+	config.Pages = pages
+	return config
+}
+
+// localizedStory returns graph with every node in locale
+func localizedStory(graph StoryGraph, locale string) StoryGraph {
+	nodes := make([]StoryNode, len(graph.Nodes))
+	for i, node := range graph.Nodes {
+		nodes[i] = node.Localized(locale)
+	}
+	graph.Nodes = nodes
+	return graph
+}
+
+// validateTranslations checks that every locale a page or node is translated into has a catalog
+func validateTranslations[T any](field string, translations map[string]T) []fieldError {
+	locales := make([]string, 0, len(translations))
+	for locale := range translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	var errs []fieldError
+	for _, locale := range locales {
+		if msg := checkLocale(locale); msg != "" {
+			errs = append(errs, fieldError{Field: field + "." + locale, Message: msg})
+		}
+	}
+	return errs
+}
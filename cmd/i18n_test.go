@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCatalogKeys(t *testing.T) {
+	en, err := readCatalog(fallbackLocale)
+	if err != nil {
+		t.Fatalf("Expected the English catalog, got %v", err)
+	}
+	for _, locale := range availableLocales() {
+		messages, err := readCatalog(locale)
+		if err != nil {
+			t.Fatalf("Expected catalog %s to parse, got %v", locale, err)
+		}
+		for key := range en {
+			if _, ok := messages[key]; !ok {
+				t.Errorf("Expected catalog %s to translate %s", locale, key)
+			}
+		}
+		for key := range messages {
+			if _, ok := en[key]; !ok {
+				t.Errorf("Expected key %s of catalog %s to be defined in the English one", key, locale)
+			}
+		}
+	}
+}
+
+func TestCatalogCache(t *testing.T) {
+	original := uiFiles
+	t.Cleanup(func() {
+		uiFiles = original
+		dropCatalogs()
+	})
+	dropCatalogs()
+	home := messagesFor("zh")["nav.home"]
+
+	// Catalogs are read once, until a change drops them
+	uiFiles = overlayFS{
+		upper: fstest.MapFS{
+			"i18n/zh.yaml": {Data: []byte("language.name: 中文\nnav.home: 首页!\n")},
+			"i18n/fr.yaml": {Data: []byte("language.name: Français\n")},
+		},
+		lower: original,
+	}
+	if got := messagesFor("zh")["nav.home"]; got != home || isAvailableLocale("fr") {
+		t.Fatalf("Expected the loaded catalogs to be kept, got %q", got)
+	}
+
+	dropCatalogs()
+	if got := messagesFor("zh")["nav.home"]; got != "首页!" {
+		t.Errorf("Expected the changed catalog to be read again, got %q", got)
+	}
+	if got := messagesFor("fr")["nav.home"]; got != messagesFor(fallbackLocale)["nav.home"] {
+		t.Errorf("Expected missing messages to fall back to English, got %q", got)
+	}
+	if name := languageName("fr"); name != "Français" || !isAvailableLocale("fr") {
+		t.Errorf("Expected the new catalog to be available, got %q", name)
+	}
+}
+
+func TestAcceptedLocale(t *testing.T) {
+	locales := []string{"en", "zh"}
+	tests := map[string]string{
+		"":                               "",
+		"zh":                             "zh",
+		"zh-CN,zh;q=0.9,en;q=0.8":        "zh",
+		"fr-FR,en;q=0.5,zh;q=0.7":        "zh",
+		"EN-gb":                          "en",
+		"fr, de;q=0.9":                   "",
+		"zh;q=0, en":                     "en",
+		"*":                              "",
+		"zh;q=invalid, en-US;q=0.3":      "en",
+		"de;q=0.9, zh-Hant-TW;q=0.9, en": "en",
+	}
+	for header, expected := range tests {
+		if locale := acceptedLocale(header, locales); locale != expected {
+			t.Errorf("Expected %q for Accept-Language %q, got %q", expected, header, locale)
+		}
+	}
+}
+
+func TestLocalized(t *testing.T) {
+	page := testPage("Model 1")
+	page.Description = "A stone"
+	page.Translations = map[string]PageTranslation{"zh": {ModelName: "模型 1"}}
+
+	if localized := page.Localized("zh"); localized.ModelName != "模型 1" || localized.Description != "A stone" {
+		t.Errorf("Expected the translated name and the untranslated description, got %+v", localized)
+	}
+	if localized := page.Localized("fr"); localized.ModelName != "Model 1" {
+		t.Errorf("Expected a page without a translation to be left as is, got %+v", localized)
+	}
+
+	node := StoryNode{ID: "1", Keyword: "Intro", Story: "Once", Translations: map[string]StoryTranslation{"zh": {Keyword: "序章", Story: "从前"}}}
+	story := localizedStory(StoryGraph{Nodes: []StoryNode{node}}, "zh")
+	if story.Nodes[0].Keyword != "序章" || story.Nodes[0].Story != "从前" || node.Keyword != "Intro" {
+		t.Errorf("Expected a translated copy of the story, got %+v", story.Nodes[0])
+	}
+}
+
+func TestValidateTranslations(t *testing.T) {
+	useTestUIFiles(t)
+
+	page := testPage("Model 1")
+	page.Translations = map[string]PageTranslation{"zh": {ModelName: "模型 1"}, "xx": {ModelName: "?"}}
+	var fields []string
+	for _, fe := range validatePage(page) {
+		fields = append(fields, fe.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"Translations.xx"}) {
+		t.Errorf("Expected an error for the locale without a catalog, got %v", fields)
+	}
+
+	if errs := validateSite(SiteConfig{Locale: "xx"}); len(errs) != 1 || errs[0].Field != "Locale" {
+		t.Errorf("Expected an error for the site locale, got %v", errs)
+	}
+	if errs := validateStoryNode(StoryNode{ID: "1", Keyword: "Intro", Translations: map[string]StoryTranslation{"xx": {}}}); len(errs) != 1 || errs[0].Field != "translations.xx" {
+		t.Errorf("Expected an error for the node translation, got %v", errs)
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.yaml")
+	os.WriteFile(filename, []byte(`Pages:
+  page1:
+    ModelSrcPath: "/static/models/obj1/object1.glb"
+    ModelIosSrcPath: "/static/models/obj1/object1.usdz"
+    PosterPath: "/static/models/obj1/object1.webp"
+    ModelName: "Model 1"
+    Translations:
+      zh:
+        ModelName: "模型 1"
+        Title: "?"
+`), 0644)
+	problems := validateConfigFile(filename)
+	if len(problems) != 1 || problems[0].Line != 10 || !strings.Contains(problems[0].Message, "Translations.zh: unknown field Title") {
+		t.Errorf("Expected the unknown translation field to be reported, got %v", problems)
+	}
+}
+
+func TestLocalizedRoutes(t *testing.T) {
+	original := storyGraphPath
+	storyGraphPath = filepath.Join(t.TempDir(), "graph.json")
+	t.Cleanup(func() { storyGraphPath = original })
+	graph := StoryGraph{Nodes: []StoryNode{{ID: "1", Keyword: "Intro", Page: "page1", Translations: map[string]StoryTranslation{"zh": {Keyword: "序章"}}}}}
+	data, _ := json.Marshal(graph)
+	os.WriteFile(storyGraphPath, data, 0644)
+
+	page := testPage("Model 1")
+	page.Translations = map[string]PageTranslation{"zh": {ModelName: "模型 1"}}
+	config := Config{Site: SiteConfig{BaseURL: "https://example.com"}, Pages: map[string]PageConfig{"page1": page, "page2": testPage("Model 2")}}
+	server, s := newTestAPIServer(t, config)
+	s.swapStory(graph)
+
+	get := func(path string, acceptLanguage string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	for _, tt := range []struct{ path, acceptLanguage string }{
+		{"/zh/models/page1", ""},
+		{"/models/page1", "zh-CN,zh;q=0.9"},
+		{"/models/page1?lang=zh", "en"},
+	} {
+		resp, body := get(tt.path, tt.acceptLanguage)
+		if resp.StatusCode != 200 || resp.Header.Get("Content-Language") != "zh" {
+			t.Fatalf("Expected %s to be served in zh, got %d %s", tt.path, resp.StatusCode, resp.Header.Get("Content-Language"))
+		}
+		for _, expected := range []string{
+			`<html lang="zh">`,
+			`<h1>模型 1</h1>`,
+			`<h3>控制面板</h3>`,
+			`href="/zh/models/page2"`,
+			`href="/zh/story?keyword=%e5%ba%8f%e7%ab%a0">序章</a>`,
+			`<link rel="canonical" href="https://example.com/zh/models/page1">`,
+			`<link rel="alternate" hreflang="en" href="https://example.com/models/page1">`,
+			`<link rel="alternate" hreflang="zh" href="https://example.com/zh/models/page1">`,
+			`<link rel="alternate" hreflang="x-default" href="https://example.com/models/page1">`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected %s to contain %s, got %s", tt.path, expected, body)
+			}
+		}
+	}
+
+	// Without a preference the page is in the locale of the site
+	resp, body := get("/models/page1", "fr")
+	if resp.Header.Get("Content-Language") != "en" || !strings.Contains(body, "<h1>Model 1</h1>") || !strings.Contains(body, `href="/models/page2"`) {
+		t.Errorf("Expected the page in English, got %s", body)
+	}
+	if !strings.Contains(resp.Header.Get("Vary"), "Accept-Language") {
+		t.Errorf("Expected the response to vary on Accept-Language, got %q", resp.Header.Get("Vary"))
+	}
+
+	if _, body := get("/zh/story", ""); !strings.Contains(body, `<html lang="zh">`) || !strings.Contains(body, "序章") {
+		t.Errorf("Expected the story page to embed the translated story, got %s", body)
+	}
+	if _, body := get("/zh/credits", ""); !strings.Contains(body, `<a href="/zh/models/page1">模型 1</a>`) {
+		t.Errorf("Expected the credits page to link the translated pages, got %s", body)
+	}
+	if _, body := get("/zh/", ""); !strings.Contains(body, `<html lang="zh">`) {
+		t.Errorf("Expected the home page in zh, got %s", body)
+	}
+
+	resp, body = get("/fr/models/page1", "")
+	if resp.StatusCode != 404 {
+		t.Errorf("Expected a locale without a catalog to be not found, got %d", resp.StatusCode)
+	}
+	resp, body = get("/zh/models/missing", "")
+	if resp.StatusCode != 404 || !strings.Contains(body, "404 - 页面未找到") {
+		t.Errorf("Expected a translated not found page, got %d %s", resp.StatusCode, body)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for path, target := range map[string]string{"/zh": "/zh/", "/zh/model1": "/zh/models/page1"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != target {
+			t.Errorf("Expected %s to redirect to %s, got %d %s", path, target, resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+}
+
+func TestBuildLocales(t *testing.T) {
+	useTestUIFiles(t)
+	outDir := t.TempDir()
+	page := testPage("Model 1")
+	page.Translations = map[string]PageTranslation{"zh": {ModelName: "模型 1"}}
+	config := Config{Pages: map[string]PageConfig{"page1": page, "page2": testPage("Model 2")}}
+
+	if err := buildSite(config, StoryGraph{}, parseTemplates(), "card", outDir); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	html, err := os.ReadFile(filepath.Join(outDir, "zh", "models", "page1", "index.html"))
+	if err != nil {
+		t.Fatalf("Expected the zh page to be built, got %v", err)
+	}
+	for _, expected := range []string{`<base href="../../">`, `<h1>模型 1</h1>`, `href="models/page2/"`, `href="../static/css/dim.css"`, `href="../models/page1/" hreflang="en"`} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("Expected the zh page to contain %s, got %s", expected, html)
+		}
+	}
+
+	var pages []pageResource
+	data, _ := os.ReadFile(filepath.Join(outDir, "zh", "api", "pages"))
+	if err := json.Unmarshal(data, &pages); err != nil || len(pages) != 2 || pages[0].ModelName != "模型 1" {
+		t.Errorf("Expected the translated pages to be exported for the zh home page, got %s (%v)", data, err)
+	}
+
+	var built []string
+	filepath.Walk(filepath.Join(outDir, "zh"), func(file string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Ext(file) == ".html" {
+			rel, _ := filepath.Rel(outDir, file)
+			built = append(built, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(built)
+	expected := []string{"zh/credits/index.html", "zh/index.html", "zh/models/page1/index.html", "zh/models/page2/index.html", "zh/story/index.html"}
+	if !reflect.DeepEqual(built, expected) {
+		t.Errorf("Expected the zh pages %v, got %v", expected, built)
+	}
+}
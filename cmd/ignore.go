@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFiles are the files listing the paths the watcher leaves alone, read in this order
+// from every directory so that .sackignore can override .gitignore
+var ignoreFiles = []string{".gitignore", ".sackignore"}
+
+// defaultIgnorePatterns are ignored unless an ignore file negates them: hidden files and
+// directories, such as .git or the swap files of editors
+var defaultIgnorePatterns = []string{".*"}
+
+// ignoreRule is a pattern of an ignore file, following the gitignore format
+type ignoreRule struct {
+	base    string // slash path of the directory of the ignore file, relative to the root, "" at the root
+	pattern *regexp.Regexp
+	negate  bool // the pattern starts with !, so it includes again what an earlier one excluded
+	dirOnly bool // the pattern ends with /, so it only matches directories
+	nested  bool // the pattern has no slash besides a trailing one, so it matches names at any depth below base
+}
+
+// ignoreMatcher tells whether a path is ignored by the ignore files of the directories from
+// the root down to it, the deeper ones taking precedence like in git. Ignore files are loaded
+// while the watched directories are walked, before the matcher is shared with the watcher.
+type ignoreMatcher struct {
+	root   string // absolute path of the project
+	rules  []ignoreRule
+	loaded map[string]bool // directories whose ignore files were read, relative to the root
+}
+
+// newIgnoreMatcher returns a matcher for the project at root, reading its top ignore files.
+// The ignore files of the directories below are read as they are walked.
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+	m := &ignoreMatcher{root: abs, loaded: make(map[string]bool)}
+	for _, line := range defaultIgnorePatterns {
+		m.addRule("", line)
+	}
+	m.loadDir("")
+	return m
+}
+
+// relative returns the slash path of p relative to the root, and false if p is outside of it
+func (m *ignoreMatcher) relative(p string) (string, bool) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(strings.TrimPrefix(abs, filepath.VolumeName(abs))), false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// load reads the ignore files of dir and of the directories between it and the root
+func (m *ignoreMatcher) load(dir string) {
+	rel, inside := m.relative(dir)
+	if !inside {
+		return
+	}
+	parent := ""
+	for _, name := range strings.Split(rel, "/") {
+		if name == "" {
+			continue
+		}
+		parent = path.Join(parent, name)
+		m.loadDir(parent)
+	}
+}
+
+// loadDir reads the ignore files of the directory at the slash path rel below the root
+func (m *ignoreMatcher) loadDir(rel string) {
+	if m.loaded[rel] {
+		return
+	}
+	m.loaded[rel] = true
+
+	for _, name := range ignoreFiles {
+		lines, err := readIgnoreFile(filepath.Join(m.root, filepath.FromSlash(rel), name))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("%sCould not read %s: %v%s", Red, path.Join(rel, name), err, Reset)
+			}
+			continue
+		}
+		for _, line := range lines {
+			m.addRule(rel, line)
+		}
+	}
+}
+
+// readIgnoreFile returns the patterns of an ignore file, without its blank lines and comments
+func readIgnoreFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := trimIgnoreLine(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue // Skip empty lines and comments
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// trimIgnoreLine removes the trailing spaces of a line, except one escaped with a backslash
+func trimIgnoreLine(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// addRule adds the pattern of an ignore file in the directory base, skipping it if invalid
+func (m *ignoreMatcher) addRule(base string, line string) {
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") && !strings.HasSuffix(line, `\/`) {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return
+	}
+
+	// A pattern with a slash at its start or middle is relative to the directory of its file,
+	// one without matches a name at any depth
+	rule.nested = !strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr, err := globToRegexp(line)
+	if err == nil {
+		rule.pattern, err = regexp.Compile(expr)
+	}
+	if err != nil {
+		log.Printf("%sInvalid ignore pattern %q in %s: %v%s", Red, line, path.Join(".", base), err, Reset)
+		return
+	}
+	m.rules = append(m.rules, rule)
+}
+
+// globToRegexp translates a gitignore pattern into a regular expression matching the whole
+// slash path it applies to: * and ? match within a name, ** across directories
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			b.WriteString("(?:.*/)?") // any number of leading directories, including none
+			i += 2
+		case glob[i:] == "**" && i > 0 && glob[i-1] == '/':
+			b.WriteString(".*") // everything inside
+			i++
+		case c == '*':
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++ // other runs of asterisks are a plain one
+			}
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if end == 0 {
+				// A ] right after the [ belongs to the class
+				next := strings.IndexByte(glob[i+2:], ']')
+				if next < 0 {
+					return "", fmt.Errorf("unterminated character class")
+				}
+				end = next + 1
+				class = glob[i+1 : i+1+end]
+			}
+			if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+				class = "^/" + class[1:] // a class never matches the separator
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// Ignored reports whether the file or directory at p is ignored, either itself or through one
+// of its parent directories, since git cannot include again a file of an excluded directory.
+// Outside of the root, where the parents are not the project's, only its name is checked.
+func (m *ignoreMatcher) Ignored(p string, isDir bool) bool {
+	rel, inside := m.relative(p)
+	if rel == "" {
+		return false
+	}
+	if !inside {
+		return m.matches(rel, isDir, false)
+	}
+
+	names := strings.Split(rel, "/")
+	for i := range names {
+		last := i == len(names)-1
+		if m.matches(strings.Join(names[:i+1], "/"), !last || isDir, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the last rule matching the slash path rel excludes it. Outside of
+// the root only the nested rules of the top ignore files apply, to the name of the path.
+func (m *ignoreMatcher) matches(rel string, isDir bool, inside bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		switch {
+		case !inside:
+			if rule.base != "" || !rule.nested {
+				continue
+			}
+			target = path.Base(rel)
+		case rule.base != "":
+			var ok bool
+			if target, ok = strings.CutPrefix(rel, rule.base+"/"); !ok {
+				continue
+			}
+		}
+		if rule.nested {
+			target = path.Base(target)
+		}
+
+		if rule.pattern.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// writeTree creates the files of a project under a temporary root, returning the root
+func writeTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(filename), 0755)
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	return root
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob, path string
+		matches    bool
+	}{
+		{"*.log", "debug.log", true},
+		{"*.log", "logs/debug.log", false},
+		{"debug?.log", "debug1.log", true},
+		{"debug?.log", "debug/.log", false},
+		{"debug[0-9].log", "debug7.log", true},
+		{"debug[!0-9].log", "debug7.log", false},
+		{"debug[!0-9].log", "debuga.log", true},
+		{"debug[!0-9].log", "debug/.log", false},
+		{"[]a].txt", "].txt", true},
+		{"**/logs", "logs", true},
+		{"**/logs", "a/b/logs", true},
+		{"logs/**", "logs/a/b.log", true},
+		{"logs/**", "logs", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/xb", false},
+		{"a**b", "a/b", false},
+		{"a**b", "axyb", true},
+		{`\#file`, "#file", true},
+		{`name\ `, "name ", true},
+		{"foo.txt", "fooXtxt", false},
+	}
+	for _, tt := range tests {
+		m := &ignoreMatcher{loaded: make(map[string]bool)}
+		m.addRule("", tt.glob)
+		if len(m.rules) != 1 {
+			t.Fatalf("Expected %q to be a valid pattern", tt.glob)
+		}
+		if matches := m.rules[0].pattern.MatchString(tt.path); matches != tt.matches {
+			t.Errorf("Expected %q matching %q to be %v", tt.glob, tt.path, tt.matches)
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore": `# build output
+*.log
+!important.log
+/dist
+build/
+docs/**/*.tmp
+\!bang
+trailing.txt   
+`,
+		".sackignore":         "!keep.log\nui/static/models/raw/\n.well-known/\n!.well-known/\n",
+		"ui/.gitignore":       "/generated\n!*.log\n",
+		"ui/html/.gitignore":  "pages/\n",
+		"ui/static/notes.txt": "",
+	})
+	m := newIgnoreMatcher(root)
+	for _, dir := range []string{"ui", "ui/html", "ui/static"} {
+		m.load(filepath.Join(root, dir))
+	}
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"debug.log", false, true},
+		{"cmd/debug.log", false, true},    // patterns without a slash match at any depth
+		{"important.log", false, false},   // negated by a later pattern
+		{"keep.log", false, false},        // .sackignore overrides .gitignore
+		{"ui/debug.log", false, false},    // a nested .gitignore overrides its parents
+		{"dist", true, true},              // anchored to the root
+		{"dist/index.html", false, true},  // inside an ignored directory
+		{"ui/dist", true, false},          // the anchor keeps it at the root
+		{"build", true, true},             // a trailing slash matches directories
+		{"build", false, false},           // but not files
+		{"cmd/build/out.go", false, true}, // nor their contents at any depth
+		{"docs/a/b/x.tmp", false, true},   // ** spans directories
+		{"docs/x.tmp", false, true},       // ** spans no directory as well
+		{"x.tmp", false, false},           // the pattern is anchored to docs
+		{"!bang", false, true},            // an escaped ! is a literal one
+		{"trailing.txt", false, true},     // trailing spaces are trimmed
+		{"ui/generated", true, true},      // anchored to ui/
+		{"generated", true, false},        // not to the root
+		{"ui/html/pages/page1.gohtml", false, true},
+		{"ui/static/models/raw/a.glb", false, true},
+		{"ui/static/notes.txt", false, false},
+		{".git", true, true}, // hidden files are ignored by default
+		{"ui/.config.yaml.swp", false, true},
+		{".well-known", true, false}, // unless negated
+		{"ui", true, false},
+	}
+	for _, tt := range tests {
+		if ignored := m.Ignored(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir); ignored != tt.ignored {
+			t.Errorf("Expected %s (dir: %v) ignored to be %v", tt.path, tt.isDir, tt.ignored)
+		}
+	}
+
+	// Outside of the root only names are matched
+	if !m.Ignored(filepath.Join(t.TempDir(), "theme", "debug.log"), false) {
+		t.Error("Expected a log file outside of the root to be ignored")
+	}
+	if m.Ignored(filepath.Join(t.TempDir(), "dist", "index.html"), false) {
+		t.Error("Expected anchored patterns not to apply outside of the root")
+	}
+}
+
+func TestAddPathsRecursively(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":                  "tmp/\n",
+		"ui/.sackignore":              "static/models/raw\n",
+		"ui/html/page.html":           "",
+		"ui/html/tmp/page.html":       "",
+		"ui/static/models/raw/a.glb":  "",
+		"ui/static/models/obj1/a.glb": "",
+		"ui/.cache/a":                 "",
+	})
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer watcher.Close()
+
+	ignore := newIgnoreMatcher(root)
+	if err := addPathsRecursively(watcher, filepath.Join(root, "ui"), ignore); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var watched []string
+	for _, dir := range watcher.WatchList() {
+		rel, _ := filepath.Rel(root, dir)
+		watched = append(watched, filepath.ToSlash(rel))
+	}
+	sort.Strings(watched)
+	expected := []string{"ui", "ui/html", "ui/static", "ui/static/models", "ui/static/models/obj1"}
+	if !reflect.DeepEqual(watched, expected) {
+		t.Errorf("Expected %v to be watched, got %v", expected, watched)
+	}
+
+	// Events are filtered with the ignore files read on the way
+	if !ignore.Ignored(filepath.Join(root, "ui", "static", "models", "raw", "b.glb"), false) {
+		t.Error("Expected the nested .sackignore to apply to events")
+	}
+}
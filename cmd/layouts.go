@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// layoutsDir holds one directory per page layout, each with its templates and a layout.yaml manifest
+const layoutsDir = "html/layouts"
+
+// layoutManifest describes a page layout: the stylesheets and scripts its template relies on
+type layoutManifest struct {
+	Description string   `yaml:"Description"`
+	Stylesheets []string `yaml:"Stylesheets"`
+	Scripts     []string `yaml:"Scripts"`
+}
+
+// readLayouts reads the manifest of every layout under ui/html/layouts, keyed by layout name
+func readLayouts() (map[string]layoutManifest, error) {
+	entries, err := fs.ReadDir(uiFiles, layoutsDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading layouts: %w", err)
+	}
+
+	layouts := make(map[string]layoutManifest)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		data, err := fs.ReadFile(uiFiles, path.Join(layoutsDir, name, "layout.yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("layout %s: %w", name, err)
+		}
+
+		var manifest layoutManifest
+		if err := yaml.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("layout %s: parsing layout.yaml: %w", name, err)
+		}
+		for _, p := range append(append([]string{}, manifest.Stylesheets...), manifest.Scripts...) {
+			if msg, _ := checkStaticPath(p); msg != "" {
+				return nil, fmt.Errorf("layout %s: %s", name, msg)
+			}
+		}
+		layouts[name] = manifest
+	}
+	if len(layouts) == 0 {
+		return nil, fmt.Errorf("no layouts found under ui/%s", layoutsDir)
+	}
+	return layouts, nil
+}
+
+// layoutNames returns the names of the available layouts in alphabetical order
+func layoutNames() []string {
+	layouts, err := readLayouts()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(layouts))
+	for name := range layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isValidLayout reports whether layout is one of the layouts found under ui/html/layouts
+func isValidLayout(layout string) bool {
+	for _, name := range layoutNames() {
+		if name == layout {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLayout returns why a page cannot use layout, or "" if it can
+func checkLayout(layout string) string {
+	if layout == "" || isValidLayout(layout) {
+		return ""
+	}
+	return fmt.Sprintf("%q is not one of the available layouts (%s)", layout, strings.Join(layoutNames(), ", "))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// useTestLayout overlays an extra "gallery" layout on top of the UI files
+func useTestLayout(t *testing.T) {
+	useTestUIFiles(t)
+	original := uiFiles
+	uiFiles = overlayFS{
+		upper: fstest.MapFS{
+			"html/layouts/gallery/layout.yaml":    {Data: []byte("Description: \"Test\"\nStylesheets:\n  - \"/static/css/gallery.css\"\nScripts:\n  - \"/static/js/gallery.js\"\n")},
+			"html/layouts/gallery/gallery.gohtml": {Data: []byte(`{{define "gallery"}}<div class="gallery">{{.PageConfig.ModelName}}</div>{{end}}`)},
+			"static/css/gallery.css":              {Data: []byte("")},
+			"static/js/gallery.js":                {Data: []byte("")},
+		},
+		lower: original,
+	}
+	t.Cleanup(func() { uiFiles = original })
+}
+
+func TestLayoutNames(t *testing.T) {
+	if names := layoutNames(); !reflect.DeepEqual(names, []string{"card", "plain"}) {
+		t.Fatalf("Expected the embedded card and plain layouts, got %v", names)
+	}
+
+	useTestLayout(t)
+	if names := layoutNames(); !reflect.DeepEqual(names, []string{"card", "gallery", "plain"}) {
+		t.Fatalf("Expected the gallery layout to be discovered, got %v", names)
+	}
+	if !isValidLayout("gallery") || isValidLayout("grid") {
+		t.Errorf("Expected only discovered layouts to be valid")
+	}
+}
+
+func TestReadLayoutsMissingStylesheet(t *testing.T) {
+	useTestLayout(t)
+	original := uiFiles
+	uiFiles = overlayFS{
+		upper: fstest.MapFS{
+			"html/layouts/broken/layout.yaml":   {Data: []byte("Stylesheets:\n  - \"/static/css/missing.css\"\n")},
+			"html/layouts/broken/broken.gohtml": {Data: []byte(`{{define "broken"}}{{end}}`)},
+		},
+		lower: original,
+	}
+
+	if _, err := readLayouts(); err == nil || !strings.Contains(err.Error(), "missing.css") {
+		t.Fatalf("Expected an error about the missing stylesheet, got %v", err)
+	}
+}
+
+func TestLoadTemplatesUndefinedLayout(t *testing.T) {
+	useTestLayout(t)
+	original := uiFiles
+	uiFiles = overlayFS{
+		upper: fstest.MapFS{
+			"html/layouts/grid/layout.yaml": {Data: []byte("Description: \"Grid\"\n")},
+			"html/layouts/grid/tile.gohtml": {Data: []byte(`{{define "tile"}}{{end}}`)},
+		},
+		lower: original,
+	}
+
+	if _, err := loadTemplates(); err == nil || !strings.Contains(err.Error(), `named "grid"`) {
+		t.Fatalf("Expected an error about the undefined grid template, got %v", err)
+	}
+}
+
+func TestRenderLayouts(t *testing.T) {
+	useTestLayout(t)
+
+	override := testPage("Model 2")
+	override.Layout = "gallery"
+	config := Config{Pages: map[string]PageConfig{"page1": testPage("Model 1"), "page2": override}}
+	tmpl := parseTemplates()
+
+	var buf strings.Builder
+	if err := renderPage(&buf, tmpl, config, StoryGraph{}, "page1", "gallery"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	html := buf.String()
+	for _, expected := range []string{`<div class="gallery">Model 1</div>`, `href="/static/css/gallery.css"`, `src="/static/js/gallery.js"`} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected the gallery layout to contain %s, got %s", expected, html)
+		}
+	}
+	if strings.Contains(html, "card-layout.css") {
+		t.Errorf("Expected the gallery layout not to link the card stylesheets")
+	}
+
+	// A page's own layout takes precedence over the site's
+	buf.Reset()
+	if err := renderPage(&buf, tmpl, config, StoryGraph{}, "page2", "card"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if html := buf.String(); !strings.Contains(html, `<div class="gallery">Model 2</div>`) || strings.Contains(html, "card-layout.css") {
+		t.Errorf("Expected page2 to use its own gallery layout, got %s", html)
+	}
+}
+
+func TestValidatePageLayout(t *testing.T) {
+	useTestUIFiles(t)
+
+	page := PageConfig{
+		ModelSrcPath:    "/static/models/obj1/object1.glb",
+		ModelIosSrcPath: "/static/models/obj1/object1.usdz",
+		PosterPath:      "/static/models/obj1/object1.webp",
+		ModelName:       "Object 1",
+		Layout:          "plain",
+	}
+	if errs := validatePage(page); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	page.Layout = "grid"
+	errs := validatePage(page)
+	if len(errs) != 1 || errs[0].Field != "Layout" || !strings.Contains(errs[0].Message, "card, plain") {
+		t.Fatalf("Expected an error listing the available layouts, got %v", errs)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -16,39 +17,38 @@ var storyGraphPath = "configs/graph.json"
 var pathsToWatch = []string{
 	"./ui/html",
 	"./ui/static",
+	"./ui/i18n",
 	"./configs",
 	"./cmd",
 }
 
 func main() {
-	// Initialize file watcher
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer watcher.Close()
-
-	for _, path := range pathsToWatch {
-		err := addPathsRecursively(watcher, path)
-		if err != nil {
-			log.Fatalf("Error setting up watcher for path %s: %v", path, err)
-		}
-	}
-
-	// Set up WebSocket server for auto-reload
-	setupWebSocket(watcher)
-
 	// Define command-line flags
 	startCmd := flag.NewFlagSet("start", flag.ExitOnError)
-	port := startCmd.Int("port", 7536, "port number to start the server")
-	layout := startCmd.String("layout", "card", "layout of the pages (card or plain)")
+	port := startCmd.Int("port", 7536, "port number to start the server, overriding Site.Port of the config")
+	layout := startCmd.String("layout", "card", "layout of the pages, one of the directories under ui/html/layouts, overriding Site.Layout of the config")
+	uiDir := startCmd.String("ui-dir", "", "directory overriding the embedded templates and static files")
+	live := startCmd.Bool("live", false, "render pages on each request instead of generating them at startup")
+	debounce := startCmd.Duration("debounce", 100*time.Millisecond, "how long files must stay unchanged before open pages are told, so the events of a save make a single reload")
+	mode := startCmd.String("mode", devMode, "dev reloads open pages when files change and shows error details, prod serves cached and compressed responses without watching files")
+
+	buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
+	outDir := buildCmd.String("out", "dist", "directory to write the static site to")
+	buildForce := buildCmd.Bool("force", false, "empty the output directory if it is not empty")
+	buildLayout := buildCmd.String("layout", "card", "layout of the pages, one of the directories under ui/html/layouts, overriding Site.Layout of the config")
+	buildUIDir := buildCmd.String("ui-dir", "", "directory overriding the embedded templates and static files")
+
+	validateCmd := flag.NewFlagSet("validate", flag.ExitOnError)
+	validateConfig := validateCmd.String("config", configPath, "path of the page configuration to validate")
+	validateGraph := validateCmd.String("graph", storyGraphPath, "path of the story graph to validate")
+	validateUIDir := validateCmd.String("ui-dir", "", "directory overriding the embedded templates and static files")
 
 	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
 	batch := generateCmd.Int("batch", 0, "generate multiple pages in batch")
 
 	// Parse command-line arguments
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: sack [start | generate]")
+		fmt.Println("Usage: sack [start | generate | build | validate]")
 		os.Exit(1)
 	}
 
@@ -58,36 +58,135 @@ func main() {
 		startCmd.Parse(os.Args[2:])
 		if len(startCmd.Args()) > 0 {
 			fmt.Println("Unexpected arguments:", startCmd.Args())
-			fmt.Println("Usage: sack start [--port PORT] [--layout LAYOUT]")
+			fmt.Println("Usage: sack start [--port PORT] [--layout LAYOUT] [--ui-dir DIR] [--live] [--mode dev|prod] [--debounce DURATION]")
 			os.Exit(1)
 		}
 		if startCmd.Parsed() {
+			if *uiDir != "" {
+				useUIDir(*uiDir)
+			}
+
+			config, err := readConfig(configPath)
+			if err != nil {
+				log.Fatalf("Error reading config file: %s", err)
+			}
+
+			// The Site block of the config provides the flags not given on the command line
+			useSiteDefaults(startCmd, config.Site)
+
 			// Validate port number
 			if *port < 1 || *port > 65535 {
 				log.Fatalf("Invalid port number: %d. Port number must be between 1 and 65535.", *port)
 				os.Exit(1)
 			}
 
-			// Validate layout
-			if *layout != "card" && *layout != "plain" {
-				log.Fatalf("Invalid layout: %s. Layout must be either 'card' or 'plain'.", *layout)
+			if msg := checkMode(*mode); msg != "" {
+				log.Fatalf("Invalid mode: %s.", msg)
+			}
+			serverMode = *mode
+			if *debounce < 0 {
+				log.Fatalf("Invalid debounce: %s. It must not be negative.", *debounce)
+			}
+
+			// Validate layout against the layouts of the UI directory in use
+			if !isValidLayout(*layout) {
+				log.Fatalf("Invalid layout: %s. Layout must be one of: %s.", *layout, strings.Join(layoutNames(), ", "))
 				os.Exit(1)
 			}
 
-			config, err := readConfig(configPath)
+			story, err := readStoryGraph(storyGraphPath)
 			if err != nil {
-				log.Fatalf("Error reading config file: %s", err)
+				log.Fatalf("Error reading story graph: %s", err)
 			}
 
 			tmpl := parseTemplates()
-			generateHTMLFiles(config, tmpl, *layout)
-			mux := setupHandlers(config)
+			if !*live {
+				generateHTMLFiles(config, story, tmpl, *layout)
+			}
+			s, err := newSite(config, story, tmpl, *layout, *live)
+			if err != nil {
+				log.Fatalf("Error: %s", err)
+			}
+			mux := setupHandlers(s)
+
+			// In prod mode the files are served as they were at startup
+			if serverMode == prodMode {
+				log.Printf("%sServing in prod mode, changes to the files need a restart%s", Cyan, Reset)
+				startServer(compressMiddleware(cacheHeadersMiddleware(mux)), *port)
+				return
+			}
+
+			// Set up WebSocket server for auto-reload, reloading the config when it changes
+			ignore := newIgnoreMatcher(".")
+			watcher := setupWatcher(*uiDir, ignore)
+			defer watcher.Close()
+			setupWebSocket(mux, watcher, ignore, *debounce, s.handleChange)
 
 			// Add the middleware to inject the WebSocket script
 			muxWithMiddleware := injectWebSocketScriptMiddleware(mux)
 
 			startServer(muxWithMiddleware, *port)
 		}
+	case "build":
+		buildCmd.Parse(os.Args[2:])
+		if len(buildCmd.Args()) > 0 {
+			fmt.Println("Unexpected arguments:", buildCmd.Args())
+			fmt.Println("Usage: sack build [--out DIR] [--force] [--layout LAYOUT] [--ui-dir DIR]")
+			os.Exit(1)
+		}
+
+		if *buildUIDir != "" {
+			useUIDir(*buildUIDir)
+		}
+
+		config, err := readConfig(configPath)
+		if err != nil {
+			log.Fatalf("Error reading config file: %s", err)
+		}
+		useSiteDefaults(buildCmd, config.Site)
+
+		if !isValidLayout(*buildLayout) {
+			log.Fatalf("Invalid layout: %s. Layout must be one of: %s.", *buildLayout, strings.Join(layoutNames(), ", "))
+		}
+		story, err := readStoryGraph(storyGraphPath)
+		if err != nil {
+			log.Fatalf("Error reading story graph: %s", err)
+		}
+
+		if err := cleanOutDir(*outDir, *buildForce); err != nil {
+			log.Fatalf("%sBuild failed: %s%s", Red, err, Reset)
+		}
+		tmpl := parseTemplates()
+		if err := buildSite(config, story, tmpl, *buildLayout, *outDir); err != nil {
+			log.Fatalf("%sBuild failed: %s%s", Red, err, Reset)
+		}
+		log.Printf("%sSite built in %s%s", Green, *outDir, Reset)
+	case "validate":
+		validateCmd.Parse(os.Args[2:])
+		if len(validateCmd.Args()) > 0 {
+			fmt.Println("Unexpected arguments:", validateCmd.Args())
+			fmt.Println("Usage: sack validate [--config PATH] [--graph PATH] [--ui-dir DIR]")
+			os.Exit(1)
+		}
+
+		if *validateUIDir != "" {
+			useUIDir(*validateUIDir)
+		}
+
+		// Story nodes are only checked against the pages if the config could be read at all
+		config, err := readConfig(*validateConfig)
+		if err != nil {
+			config.Pages = nil
+		}
+		problems := append(validateConfigFile(*validateConfig), validateGraphFile(*validateGraph, config.Pages)...)
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			fmt.Printf("%s%d problem(s) found%s\n", Red, len(problems), Reset)
+			os.Exit(1)
+		}
+		fmt.Printf("%s%s and %s are valid%s\n", Green, *validateConfig, *validateGraph, Reset)
 	case "generate":
 		generateCmd.Parse(os.Args[2:])
 		if generateCmd.Parsed() {
@@ -110,11 +209,36 @@ func main() {
 			}
 		}
 	default:
-		fmt.Println("Usage: sack [start | generate]")
+		fmt.Println("Usage: sack [start | generate | build | validate]")
 		os.Exit(1)
 	}
 }
 
+// setupWatcher creates a file watcher over all the paths to watch that exist, plus uiDir if
+// given, leaving out the directories the ignore files exclude
+func setupWatcher(uiDir string, ignore *ignoreMatcher) *fsnotify.Watcher {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paths := pathsToWatch
+	if uiDir != "" {
+		paths = append(paths, uiDir)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			log.Printf("%sSkipping missing watch path: %s%s", Yellow, path, Reset)
+			continue
+		}
+		err := addPathsRecursively(watcher, path, ignore)
+		if err != nil {
+			log.Fatalf("Error setting up watcher for path %s: %v", path, err)
+		}
+	}
+	return watcher
+}
+
 // interactiveGenerate prompts the user for input to create a new page configuration
 func interactiveGenerate() {
 	reader := bufio.NewReader(os.Stdin)
@@ -152,6 +276,29 @@ func interactiveGenerate() {
 	designerName, _ := reader.ReadString('\n')
 	designerName = strings.TrimSpace(designerName)
 
+	// Prompt for specs until an empty line, asking again for any that cannot be parsed
+	var specs []Spec
+	fmt.Println("Enter specs as Label=Value [Unit], e.g. Dimensions=32 x 24 x 13 mm (empty line to finish)")
+	fmt.Printf("Supported units: %s\n", strings.Join(unitNames(), ", "))
+	for {
+		fmt.Print("Enter Spec: ")
+		input, err := reader.ReadString('\n')
+		input = strings.TrimSpace(input)
+		if input == "" {
+			break
+		}
+
+		spec, specErr := parseSpec(input)
+		if specErr != nil {
+			fmt.Printf("%sInvalid spec: %s%s\n", Red, specErr, Reset)
+		} else {
+			specs = append(specs, spec)
+		}
+		if err != nil {
+			break // end of input
+		}
+	}
+
 	// Create new PageConfig with user input
 	pageConfig := PageConfig{
 		ModelSrcPath:    modelSrcPath,
@@ -161,6 +308,7 @@ func interactiveGenerate() {
 		ModelName:       modelName,
 		DesignerWebsite: designerWebsite,
 		DesignerName:    designerName,
+		Specs:           specs,
 	}
 
 	// Add new page to config
@@ -168,7 +316,9 @@ func interactiveGenerate() {
 	config.Pages[pageName] = pageConfig
 
 	// Save updated config
-	writeConfig(configPath, config)
+	if err := writeConfig(configPath, config); err != nil {
+		log.Fatalf("Error writing config file: %s", err)
+	}
 }
 
 // batchGenerate creates multiple new page configurations automatically
@@ -192,5 +342,7 @@ func batchGenerate(config Config, count int) {
 		config.Pages[pageName] = pageConfig
 	}
 
-	writeConfig(configPath, config)
+	if err := writeConfig(configPath, config); err != nil {
+		log.Fatalf("Error writing config file: %s", err)
+	}
 }
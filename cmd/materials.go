@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaterialPreset is a named finish of a model, shown as a swatch that applies it. Metalness
+// and Roughness are left as they are when not set, and Texture replaces the base color map.
+type MaterialPreset struct {
+	Name      string   `yaml:"Name"`
+	BaseColor string   `yaml:"BaseColor"`
+	Metalness *float64 `yaml:"Metalness,omitempty"`
+	Roughness *float64 `yaml:"Roughness,omitempty"`
+	Emissive  string   `yaml:"Emissive,omitempty"`
+	Texture   string   `yaml:"Texture,omitempty"`
+	Swatch    string   `yaml:"Swatch,omitempty"` // image shown instead of a plain color swatch
+	Default   bool     `yaml:"Default,omitempty"`
+}
+
+// defaultMaterials are the rainbow swatches shown for pages that declare no presets
+var defaultMaterials = []MaterialPreset{
+	{Name: "Red", BaseColor: "#e81416", Swatch: "/static/img/red.png"},
+	{Name: "Orange", BaseColor: "#ffa500", Swatch: "/static/img/orange.png"},
+	{Name: "Yellow", BaseColor: "#faeb36", Swatch: "/static/img/yellow.png"},
+	{Name: "Green", BaseColor: "#79c314", Swatch: "/static/img/green.png"},
+	{Name: "Blue", BaseColor: "#487de7", Swatch: "/static/img/blue.png"},
+	{Name: "Indigo", BaseColor: "#4b369d", Swatch: "/static/img/indigo.png"},
+	{Name: "Violet", BaseColor: "#70369d", Swatch: "/static/img/violet.png"},
+}
+
+// hexColorPattern matches #rrggbb colors with an optional alpha channel
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}([0-9A-Fa-f]{2})?$`)
+
+// MaterialPresets returns the material presets of the page, or the rainbow swatches if it has none
+func (p PageConfig) MaterialPresets() []MaterialPreset {
+	if len(p.Materials) == 0 {
+		return defaultMaterials
+	}
+	return p.Materials
+}
+
+// InitialMetalness returns the value the metalness slider starts at, the one of the default
+// preset of the page if it sets one
+func (p PageConfig) InitialMetalness() float64 {
+	if m, ok := p.defaultMaterial(); ok && m.Metalness != nil {
+		return *m.Metalness
+	}
+	return 1
+}
+
+// InitialRoughness returns the value the roughness slider starts at, the one of the default
+// preset of the page if it sets one
+func (p PageConfig) InitialRoughness() float64 {
+	if m, ok := p.defaultMaterial(); ok && m.Roughness != nil {
+		return *m.Roughness
+	}
+	return 0
+}
+
+// defaultMaterial returns the preset applied when the model is loaded, if the page has one
+func (p PageConfig) defaultMaterial() (MaterialPreset, bool) {
+	for _, m := range p.MaterialPresets() {
+		if m.Default {
+			return m, true
+		}
+	}
+	return MaterialPreset{}, false
+}
+
+// validateMaterials checks the material presets of a page, returning every invalid field
+func validateMaterials(materials []MaterialPreset) []fieldError {
+	var errs []fieldError
+	names := make(map[string]int)
+	defaults := 0
+
+	for i, m := range materials {
+		field := func(name string) string { return fmt.Sprintf("Materials[%d].%s", i, name) }
+
+		if strings.TrimSpace(m.Name) == "" {
+			errs = append(errs, fieldError{Field: field("Name"), Message: "is required"})
+		} else if other, ok := names[m.Name]; ok {
+			errs = append(errs, fieldError{Field: field("Name"), Message: fmt.Sprintf("%q is already used by Materials[%d]", m.Name, other)})
+		} else {
+			names[m.Name] = i
+		}
+
+		if m.BaseColor == "" {
+			errs = append(errs, fieldError{Field: field("BaseColor"), Message: "is required"})
+		} else if !hexColorPattern.MatchString(m.BaseColor) {
+			errs = append(errs, fieldError{Field: field("BaseColor"), Message: fmt.Sprintf("%q must be a color like #ffa500", m.BaseColor)})
+		}
+		if m.Emissive != "" && !hexColorPattern.MatchString(m.Emissive) {
+			errs = append(errs, fieldError{Field: field("Emissive"), Message: fmt.Sprintf("%q must be a color like #ffa500", m.Emissive)})
+		}
+
+		if m.Metalness != nil && (*m.Metalness < 0 || *m.Metalness > 1) {
+			errs = append(errs, fieldError{Field: field("Metalness"), Message: "must be between 0 and 1"})
+		}
+		if m.Roughness != nil && (*m.Roughness < 0 || *m.Roughness > 1) {
+			errs = append(errs, fieldError{Field: field("Roughness"), Message: "must be between 0 and 1"})
+		}
+		if msg, missing := checkStaticPath(m.Texture); msg != "" {
+			errs = append(errs, fieldError{Field: field("Texture"), Message: msg, Missing: missing})
+		}
+		if msg, missing := checkStaticPath(m.Swatch); msg != "" {
+			errs = append(errs, fieldError{Field: field("Swatch"), Message: msg, Missing: missing})
+		}
+
+		if m.Default {
+			defaults++
+			if defaults > 1 {
+				errs = append(errs, fieldError{Field: field("Default"), Message: "only one preset can be the default"})
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// factor returns a pointer to a metalness or roughness value
+func factor(v float64) *float64 {
+	return &v
+}
+
+func TestValidateMaterials(t *testing.T) {
+	useTestUIFiles(t)
+
+	valid := []MaterialPreset{
+		{Name: "Brushed steel", BaseColor: "#c0c0c0", Metalness: factor(1), Roughness: factor(0.4), Default: true},
+		{Name: "Glow", BaseColor: "#000000ff", Emissive: "#39ff14", Texture: "/static/models/obj1/object1.webp"},
+	}
+	if errs := validateMaterials(valid); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	invalid := []MaterialPreset{
+		{Name: "Steel", BaseColor: "silver", Metalness: factor(2), Default: true},
+		{Name: "Steel", Emissive: "#fff", Roughness: factor(-1), Swatch: "/static/img/missing.png", Default: true},
+	}
+	var fields []string
+	for _, fe := range validateMaterials(invalid) {
+		fields = append(fields, fe.Field)
+	}
+	expected := []string{
+		"Materials[0].BaseColor", "Materials[0].Metalness",
+		"Materials[1].Name", "Materials[1].BaseColor", "Materials[1].Emissive", "Materials[1].Roughness", "Materials[1].Swatch", "Materials[1].Default",
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Expected errors for %v, got %v", expected, fields)
+	}
+}
+
+func TestRenderMaterials(t *testing.T) {
+	custom := testPage("Model 1")
+	custom.Materials = []MaterialPreset{
+		{Name: "Matte", BaseColor: "#224466", Metalness: factor(0), Roughness: factor(1), Default: true},
+		{Name: "Gold", BaseColor: "#ffd700", Metalness: factor(1), Swatch: "/static/img/yellow.png"},
+	}
+	config := Config{Pages: map[string]PageConfig{"page1": custom, "page2": testPage("Model 2")}}
+
+	for _, layout := range []string{"card", "plain"} {
+		var buf strings.Builder
+		if err := renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page1", layout); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		html := buf.String()
+		if !strings.Contains(html, `data-color="#224466" data-metalness="0" data-roughness="1" data-default>`) {
+			t.Errorf("Expected %s layout to render the default preset, got %s", layout, html)
+		}
+		if !strings.Contains(html, `id="metalness" type="range" min="0" max="1" step="0.01" value="0"`) || !strings.Contains(html, `id="roughness" type="range" min="0" max="1" step="0.01" value="1"`) {
+			t.Errorf("Expected %s layout to start the sliders at the default preset", layout)
+		}
+		if !strings.Contains(html, `src="/static/img/yellow.png" alt="Gold"`) || strings.Contains(html, "red.png") {
+			t.Errorf("Expected %s layout to render only the page's presets", layout)
+		}
+
+		buf.Reset()
+		renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page2", layout)
+		if html := buf.String(); strings.Count(html, `class="double-size swatch"`) != 7 || strings.Contains(html, "data-default") {
+			t.Errorf("Expected %s layout to fall back to the rainbow swatches, got %s", layout, html)
+		}
+		if html := buf.String(); !strings.Contains(html, `id="metalness" type="range" min="0" max="1" step="0.01" value="1"`) || !strings.Contains(html, `id="roughness" type="range" min="0" max="1" step="0.01" value="0"`) {
+			t.Errorf("Expected %s layout to start the sliders at their usual values", layout)
+		}
+	}
+}
@@ -1,11 +1,144 @@
 package main
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
 )
 
+// liveReloadScript connects the page to the auto-reload WebSocket of the server it was served
+// from, %s being the base path the server is mounted at. It applies the reloadMessage of each
+// change, showing why a change was rejected even across the reload of the page, and when the
+// server goes away, retries with a growing delay and reloads the page once the server is back.
+const liveReloadScript = `<script>
+	(function () {
+		const url = (location.protocol === "https:" ? "wss://" : "ws://") + location.host + %s + "/ws";
+		const errorKey = "sack-rejected-change";
+		let delay = 500;
+		let disconnected = false;
+		let rejected = "";
+
+		function showError(message) {
+			let overlay = document.getElementById("sack-error-overlay");
+			if (!overlay) {
+				overlay = document.createElement("pre");
+				overlay.id = "sack-error-overlay";
+				overlay.title = "Click to dismiss";
+				overlay.style.cssText = "position:fixed;inset:0;z-index:99999;margin:0;padding:2em;overflow:auto;" +
+					"background:rgba(20,0,0,0.9);color:#ff8a80;font:14px/1.5 monospace;white-space:pre-wrap;cursor:pointer";
+				overlay.onclick = () => overlay.remove();
+				document.body.appendChild(overlay);
+			}
+			overlay.textContent = "Change rejected, still serving the previous version:\n\n" + message;
+		}
+
+		// Reloads the page, keeping the reason of a rejected change to show it once reloaded
+		function reload() {
+			if (rejected) {
+				sessionStorage.setItem(errorKey, rejected);
+			}
+			window.location.reload();
+		}
+
+		function refers(value, path) {
+			return value !== null && new URL(value, location.href).pathname === path;
+		}
+
+		// Loads the new stylesheet next to the old one, removing the old one once it applies,
+		// so the page keeps its state and does not flash
+		function swapStylesheet(path) {
+			document.querySelectorAll('link[rel="stylesheet"]').forEach(link => {
+				if (refers(link.getAttribute("href"), path)) {
+					const fresh = link.cloneNode();
+					fresh.href = path + "?reload=" + Date.now();
+					fresh.onload = () => link.remove();
+					link.after(fresh);
+				}
+			});
+		}
+
+		// Has the model viewers fetch the changed models or posters again, keeping the camera
+		function refetchModel(paths) {
+			const viewers = document.querySelectorAll("model-viewer");
+			if (viewers.length === 0) {
+				reload();
+				return;
+			}
+			viewers.forEach(viewer => {
+				["src", "ios-src", "poster"].forEach(name => {
+					const path = paths.find(path => refers(viewer.getAttribute(name), path));
+					if (path) {
+						viewer.setAttribute(name, path + "?reload=" + Date.now());
+					}
+				});
+			});
+		}
+
+		// Reloads a model page only if it is one of the changed pages, and every other page,
+		// which may list any of them
+		function reloadPages(slugs) {
+			const match = location.pathname.match(/\/models\/([^/]+)\/?$/);
+			if (!match || slugs.includes(decodeURIComponent(match[1]))) {
+				reload();
+			}
+		}
+
+		// Applies a change, along with the error of the changes of its batch that were rejected
+		function handle(message) {
+			rejected = message.message || "";
+			switch (message.type) {
+			case "css":
+				message.paths.forEach(swapStylesheet);
+				break;
+			case "model":
+				refetchModel(message.paths);
+				break;
+			case "pages":
+				reloadPages(message.pages);
+				break;
+			case "error":
+				break;
+			default:
+				reload();
+				return;
+			}
+			if (rejected) {
+				showError(rejected);
+			}
+		}
+
+		function connect() {
+			const ws = new WebSocket(url);
+			ws.onopen = function () {
+				// The server restarted while the page was open, so it may serve something else now
+				if (disconnected) {
+					window.location.reload();
+				}
+				delay = 500;
+			};
+			ws.onmessage = function (event) {
+				handle(JSON.parse(event.data));
+			};
+			ws.onclose = function () {
+				disconnected = true;
+				setTimeout(connect, delay);
+				delay = Math.min(delay * 2, 10000);
+			};
+		}
+		const stashed = sessionStorage.getItem(errorKey);
+		if (stashed) {
+			sessionStorage.removeItem(errorKey);
+			showError(stashed);
+		}
+		connect();
+	})();
+</script>`
+
 // ResponseRecorder to capture the response body
 type responseRecorder struct {
 	http.ResponseWriter
@@ -16,20 +149,19 @@ type responseRecorder struct {
 // the middleware to inject the WebSocket script into HTML responses
 func injectWebSocketScriptMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The WebSocket takes over the connection, which the recorder cannot hand over
+		if r.URL.Path == "/ws" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Capture the response
 		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(rr, r)
 
 		// If the content type is HTML, inject the WebSocket script
 		if rr.Header().Get("Content-Type") == "text/html; charset=utf-8" {
-			injectedContent := strings.ReplaceAll(rr.body.String(), "</body>", `<script>
-				const ws = new WebSocket("ws://localhost:7536/ws");
-				ws.onmessage = function(event) {
-					if (event.data === "reload") {
-						window.location.reload();
-					}
-				};
-			</script></body>`)
+			injectedContent := strings.ReplaceAll(rr.body.String(), "</body>", reloadScript(r)+"</body>")
 
 			w.Header().Set("Content-Length", fmt.Sprint(len(injectedContent)))
 			w.WriteHeader(rr.statusCode)
@@ -42,6 +174,28 @@ func injectWebSocketScriptMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// reloadScript returns the auto-reload script for a page served in answer to r. The scheme and
+// host are those the browser reached the page on, so it works on any port, address or behind TLS;
+// a reverse proxy serving the site under a path tells it through the X-Forwarded-Prefix header.
+func reloadScript(r *http.Request) string {
+	base, _ := json.Marshal(forwardedPrefix(r))
+	return fmt.Sprintf(liveReloadScript, base)
+}
+
+// forwardedPrefix returns the path a reverse proxy serves the site under, without a trailing
+// slash, or "" if it serves it at the root
+func forwardedPrefix(r *http.Request) string {
+	prefix := strings.TrimSpace(r.Header.Get("X-Forwarded-Prefix"))
+	if !strings.HasPrefix(prefix, "/") {
+		return ""
+	}
+	prefix = path.Clean(prefix)
+	if prefix == "/" {
+		return ""
+	}
+	return prefix
+}
+
 func (rr *responseRecorder) Write(p []byte) (int, error) {
 	return rr.body.Write(p)
 }
@@ -49,3 +203,122 @@ func (rr *responseRecorder) Write(p []byte) (int, error) {
 func (rr *responseRecorder) WriteHeader(statusCode int) {
 	rr.statusCode = statusCode
 }
+
+// staticCacheControl lets browsers and proxies keep the static files for a day
+const staticCacheControl = "public, max-age=86400"
+
+// cacheHeadersMiddleware lets the static files be cached, and has every other response checked
+// with the server before it is reused, since pages and the API follow config.yaml
+func cacheHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, static := strings.CutPrefix(r.URL.Path, "/static/")
+		if info, err := fs.Stat(uiFiles, path.Join("static", name)); static && err == nil && !info.IsDir() {
+			w.Header().Set("Cache-Control", staticCacheControl)
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compressibleTypes are the content types worth compressing, the others being compressed already
+var compressibleTypes = []string{"text/", "application/json", "application/javascript", "application/xml", "image/svg+xml", "model/gltf+json"}
+
+// compressMiddleware gzips the text responses of the clients accepting it. Range requests are
+// left alone, since their byte offsets refer to the uncompressed file.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, "gzip") && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err != nil || value == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body of a response once its content type is known,
+// which is on its first write
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	started bool
+	gz      *gzip.Writer
+}
+
+func (gw *gzipResponseWriter) WriteHeader(statusCode int) {
+	if gw.status == 0 {
+		gw.status = statusCode
+	}
+}
+
+func (gw *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !gw.started {
+		gw.start(p)
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(p)
+	}
+	return gw.ResponseWriter.Write(p)
+}
+
+// start sends the headers of the response, compressing its body if its content type is
+// compressible. p is the start of the body, sniffed if no content type is set.
+func (gw *gzipResponseWriter) start(p []byte) {
+	gw.started = true
+	if gw.status == 0 {
+		gw.status = http.StatusOK
+	}
+
+	header := gw.Header()
+	if header.Get("Content-Type") == "" && len(p) > 0 {
+		header.Set("Content-Type", http.DetectContentType(p))
+	}
+	if isCompressible(header.Get("Content-Type")) && header.Get("Content-Encoding") == "" && gw.status != http.StatusNoContent && gw.status != http.StatusNotModified {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(gw.status)
+}
+
+// close flushes the compressed body, or sends the headers of a response without one
+func (gw *gzipResponseWriter) close() {
+	if !gw.started {
+		gw.start(nil)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+	}
+}
+
+// isCompressible reports whether responses of contentType are worth compressing
+func isCompressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestForwardedPrefix(t *testing.T) {
+	tests := map[string]string{
+		"":             "",
+		"/":            "",
+		"/sack":        "/sack",
+		"/sack/":       "/sack",
+		" /a//b/../c ": "/a/c",
+		"sack":         "",
+	}
+	for header, expected := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Forwarded-Prefix", header)
+		if prefix := forwardedPrefix(r); prefix != expected {
+			t.Errorf("Expected %q for X-Forwarded-Prefix %q, got %q", expected, header, prefix)
+		}
+	}
+}
+
+func TestInjectReloadScript(t *testing.T) {
+	handler := injectWebSocketScriptMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body></body></html>"))
+	}))
+
+	get := func(prefix string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		if prefix != "" {
+			r.Header.Set("X-Forwarded-Prefix", prefix)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	body := get("")
+	if strings.Contains(body, "localhost") || !strings.Contains(body, `location.host + "" + "/ws"`) || !strings.HasSuffix(body, "</script></body></html>") {
+		t.Errorf("Expected the WebSocket URL to be derived from the location, got %s", body)
+	}
+	if body := get("/sack"); !strings.Contains(body, `location.host + "/sack" + "/ws"`) {
+		t.Errorf("Expected the forwarded prefix in the WebSocket URL, got %s", body)
+	}
+	if body := get(`/"</script><script>alert(1)`); strings.Contains(body, "<script>alert") {
+		t.Errorf("Expected the forwarded prefix to be escaped, got %s", body)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, gzip;q=0.8": true,
+		"br, GZIP":            true,
+		"gzip;q=0, deflate":   false,
+		"*":                   true,
+		"identity":            false,
+		"gzip;q=invalid, br":  false,
+	}
+	for header, expected := range tests {
+		if accepts := acceptsGzip(header); accepts != expected {
+			t.Errorf("Expected %v for Accept-Encoding %q, got %v", expected, header, accepts)
+		}
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	useTestUIFiles(t)
+	s, err := newSite(Config{}, StoryGraph{}, parseTemplates(), "card", true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	handler := compressMiddleware(cacheHeadersMiddleware(setupHandlers(s)))
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	gzipped := http.Header{"Accept-Encoding": {"gzip"}}
+	w := get("/static/css/errors.css", gzipped)
+	if w.Code != 200 || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("Expected a compressed stylesheet, got %d %v", w.Code, w.Header())
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip body, got %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	if !strings.Contains(string(body), ".container") {
+		t.Errorf("Expected the stylesheet, got %s", body)
+	}
+	if w.Header().Get("Cache-Control") != staticCacheControl || !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+		t.Errorf("Expected a cacheable response varying on Accept-Encoding, got %v", w.Header())
+	}
+
+	if w := get("/static/css/errors.css", nil); w.Header().Get("Content-Encoding") != "" || !strings.Contains(w.Body.String(), ".container") {
+		t.Errorf("Expected an uncompressed stylesheet without Accept-Encoding, got %v", w.Header())
+	}
+	if w := get("/static/css/errors.css", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}}); w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 10 {
+		t.Errorf("Expected an uncompressed range, got %d %v", w.Code, w.Header())
+	}
+	if w := get("/static/img/missing.png", gzipped); w.Code != 404 || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("Expected a missing file not to be cached, got %d %v", w.Code, w.Header())
+	}
+	if w := get("/static/img/blue.png", gzipped); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected an image not to be compressed again, got %v", w.Header())
+	}
+}
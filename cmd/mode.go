@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"sync"
+)
+
+// The modes the server can run in. dev watches the files, reloads open pages when they change
+// and shows the details of errors; prod serves cached, compressed responses and nothing else.
+const (
+	devMode  = "dev"
+	prodMode = "prod"
+)
+
+// serverMode is the mode the server was started in
+var serverMode = devMode
+
+// standaloneTemplates holds the parsed standalone pages in prod mode, where the files never change
+var standaloneTemplates sync.Map
+
+// checkMode returns why mode is not a mode the server can run in, or "" if it is
+func checkMode(mode string) string {
+	if mode == devMode || mode == prodMode {
+		return ""
+	}
+	return fmt.Sprintf("%q is not a mode, must be %s or %s", mode, devMode, prodMode)
+}
+
+// standaloneTemplate returns the parsed standalone page ui/html/name. It is parsed again on
+// every request in dev mode, so edits show up right away, and only once in prod mode.
+func standaloneTemplate(name string) (*template.Template, error) {
+	if serverMode != prodMode {
+		return template.ParseFS(uiFiles, "html/"+name)
+	}
+	if ts, ok := standaloneTemplates.Load(name); ok {
+		return ts.(*template.Template), nil
+	}
+
+	ts, err := template.ParseFS(uiFiles, "html/"+name)
+	if err != nil {
+		return nil, err
+	}
+	standaloneTemplates.Store(name, ts)
+	return ts, nil
+}
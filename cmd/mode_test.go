@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// useTestMode runs the server in mode for the duration of a test
+func useTestMode(t *testing.T, mode string) {
+	original := serverMode
+	serverMode = mode
+	t.Cleanup(func() {
+		serverMode = original
+		standaloneTemplates.Range(func(name, _ any) bool {
+			standaloneTemplates.Delete(name)
+			return true
+		})
+	})
+}
+
+func TestCheckMode(t *testing.T) {
+	for _, mode := range []string{devMode, prodMode} {
+		if msg := checkMode(mode); msg != "" {
+			t.Errorf("Expected %s to be a mode, got %s", mode, msg)
+		}
+	}
+	if msg := checkMode("production"); !strings.Contains(msg, "must be dev or prod") {
+		t.Errorf("Expected an unknown mode to be rejected, got %q", msg)
+	}
+}
+
+func TestErrorPageDetail(t *testing.T) {
+	useTestUIFiles(t)
+
+	for mode, detailed := range map[string]bool{devMode: true, prodMode: false} {
+		useTestMode(t, mode)
+
+		w := httptest.NewRecorder()
+		serverError(w, httptest.NewRequest("GET", "/models/page1?x=1", nil), errors.New("template: <missing>"))
+		body := w.Body.String()
+		if w.Code != 500 || strings.Contains(body, "GET /models/page1?x=1\n\ntemplate: &lt;missing&gt;") != detailed {
+			t.Errorf("Expected the error details in %s mode to be shown: %v, got %s", mode, detailed, body)
+		}
+
+		w = httptest.NewRecorder()
+		notFound(w, httptest.NewRequest("GET", "/missing", nil))
+		if body := w.Body.String(); w.Code != 404 || strings.Contains(body, "No route or page matches GET /missing") != detailed {
+			t.Errorf("Expected the request in %s mode to be shown: %v, got %s", mode, detailed, body)
+		}
+	}
+}
+
+func TestStandaloneTemplate(t *testing.T) {
+	original := uiFiles
+	t.Cleanup(func() { uiFiles = original })
+	uiFiles = fstest.MapFS{"html/page.html": {Data: []byte("first")}}
+
+	render := func() string {
+		ts, err := standaloneTemplate("page.html")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var buf strings.Builder
+		ts.Execute(&buf, nil)
+		return buf.String()
+	}
+
+	useTestMode(t, devMode)
+	render()
+	uiFiles = fstest.MapFS{"html/page.html": {Data: []byte("second")}}
+	if page := render(); page != "second" {
+		t.Errorf("Expected the page to be parsed again in dev mode, got %s", page)
+	}
+
+	useTestMode(t, prodMode)
+	render()
+	uiFiles = fstest.MapFS{"html/page.html": {Data: []byte("third")}}
+	if page := render(); page != "second" {
+		t.Errorf("Expected the parsed page to be kept in prod mode, got %s", page)
+	}
+}
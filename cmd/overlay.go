@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/lemorage/sack/configs"
+	"github.com/lemorage/sack/ui"
+)
+
+// uiFiles is the file system the templates and static assets are read from
+var uiFiles fs.FS = ui.Files
+
+// overlayFS serves files from upper when they exist there, falling back to lower otherwise
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+// useUIDir overlays the directory dir on top of the embedded UI files
+func useUIDir(dir string) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		log.Fatalf("Invalid UI directory: %s", dir)
+	}
+	uiFiles = overlayFS{upper: os.DirFS(dir), lower: ui.Files}
+	log.Printf("%sServing UI files from %s%s", Green, dir, Reset)
+}
+
+// Open opens the named file from the upper file system, or from the lower one if it is missing
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.upper.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	return o.lower.Open(name)
+}
+
+// ReadDir merges the entries of the named directory in both file systems, preferring upper
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upperEntries, upperErr := fs.ReadDir(o.upper, name)
+	lowerEntries, lowerErr := fs.ReadDir(o.lower, name)
+	if upperErr != nil && lowerErr != nil {
+		return nil, lowerErr
+	}
+
+	entries := make(map[string]fs.DirEntry)
+	for _, entry := range lowerEntries {
+		entries[entry.Name()] = entry
+	}
+	for _, entry := range upperEntries {
+		entries[entry.Name()] = entry
+	}
+
+	merged := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		merged = append(merged, entry)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+	return merged, nil
+}
+
+// readConfigFile reads a configuration file from disk, falling back to the sample
+// configuration compiled into the binary when the file does not exist
+func readConfigFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) && (filename == configPath || filename == storyGraphPath) {
+		if embedded, embedErr := fs.ReadFile(configs.Files, path.Base(filename)); embedErr == nil {
+			return embedded, nil
+		}
+	}
+	return data, err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOverlayFS(t *testing.T) {
+	overlay := overlayFS{
+		upper: fstest.MapFS{
+			"static/css/home.css":  {Data: []byte("custom")},
+			"static/css/theme.css": {Data: []byte("theme")},
+		},
+		lower: fstest.MapFS{
+			"static/css/home.css":  {Data: []byte("default")},
+			"static/css/graph.css": {Data: []byte("graph")},
+		},
+	}
+
+	data, err := fs.ReadFile(overlay, "static/css/home.css")
+	if err != nil || string(data) != "custom" {
+		t.Fatalf("Expected overridden file, got %q (%v)", data, err)
+	}
+
+	data, err = fs.ReadFile(overlay, "static/css/graph.css")
+	if err != nil || string(data) != "graph" {
+		t.Fatalf("Expected embedded fallback, got %q (%v)", data, err)
+	}
+
+	matches, err := fs.Glob(overlay, "static/css/*.css")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"static/css/graph.css", "static/css/home.css", "static/css/theme.css"}
+	if len(matches) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, matches)
+	}
+	for i, match := range matches {
+		if match != expected[i] {
+			t.Errorf("Expected %s at index %d, got %s", expected[i], i, match)
+		}
+	}
+}
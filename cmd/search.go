@@ -0,0 +1,366 @@
+package main
+
+import (
+	"html"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultSearchLimit and maxSearchLimit bound the number of results of a search
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// snippetLength is the number of characters of text shown around the first match of a result
+const snippetLength = 160
+
+// prefixMatchWeight discounts terms that only start with a query term, so whole words rank first
+const prefixMatchWeight = 0.5
+
+// searchResult is one match of a search, as returned by /api/search
+type searchResult struct {
+	Kind    string  `json:"kind"` // model or story
+	Key     string  `json:"key"`  // page key or story node id
+	Title   string  `json:"title"`
+	URL     string  `json:"url"`
+	Poster  string  `json:"poster,omitempty"`
+	Snippet string  `json:"snippet"` // HTML excerpt of the best matching text, with the matches in <mark>
+	Score   float64 `json:"score"`
+
+	titles map[string]string // title by locale, when translated
+}
+
+// searchResponse is the JSON body of /api/search
+type searchResponse struct {
+	Query   string         `json:"query"`
+	Results []searchResult `json:"results"`
+}
+
+// searchField is a piece of text of an indexed document, weighted by how telling a match in it is
+type searchField struct {
+	text    string
+	weight  float64
+	title   bool // the field is the title of the result, so it is not repeated as its snippet
+	summary bool // the field describes the document, shown as its snippet when only the title matches
+}
+
+// searchDoc is a model page or story node of the index
+type searchDoc struct {
+	result searchResult // the result without its score and snippet
+	fields []searchField
+}
+
+// searchPosting records that a term appears count times in a field of a document
+type searchPosting struct {
+	doc, field, count int
+}
+
+// searchToken is a term of a text along with its byte offsets, used to highlight it
+type searchToken struct {
+	term       string
+	start, end int
+}
+
+// searchIndex is an inverted index of the text of the model pages and story nodes, rebuilt
+// whenever the config or the story graph changes
+type searchIndex struct {
+	docs  []searchDoc
+	terms map[string][]searchPosting
+	words []string // indexed terms in order, to look up the ones a query term is a prefix of
+}
+
+// tokenize splits text into lowercase terms: runs of letters and digits, and single characters
+// of the scripts written without spaces such as Chinese
+func tokenize(text string) []searchToken {
+	var tokens []searchToken
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, searchToken{strings.ToLower(text[start:end]), start, end})
+			start = -1
+		}
+	}
+
+	for i, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			flush(i)
+			tokens = append(tokens, searchToken{string(r), i, i + utf8.RuneLen(r)})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// newSearchIndex indexes the name, description, designer and specs of every page and the
+// text of every story node, including their translations
+func newSearchIndex(config Config, story StoryGraph) *searchIndex {
+	index := &searchIndex{terms: make(map[string][]searchPosting)}
+
+	for _, key := range sortedPageKeys(config.Pages) {
+		page := config.Pages[key]
+		doc := searchDoc{
+			result: searchResult{Kind: "model", Key: key, Title: page.ModelName, URL: "/models/" + pageSlug(key, page), Poster: page.PosterPath, titles: make(map[string]string)},
+			fields: []searchField{
+				{text: page.ModelName, weight: 5, title: true},
+				{text: page.DesignerName, weight: 3},
+				{text: page.Description, weight: 2, summary: true},
+			},
+		}
+		for _, spec := range page.Specs {
+			doc.fields = append(doc.fields, searchField{text: strings.TrimSpace(spec.Label + ": " + spec.Value + " " + spec.Unit), weight: 1})
+		}
+		for locale, t := range page.Translations {
+			if t.ModelName != "" {
+				doc.result.titles[locale] = t.ModelName
+			}
+			doc.fields = append(doc.fields, searchField{text: t.ModelName, weight: 5, title: true}, searchField{text: t.Description, weight: 2})
+		}
+		index.add(doc)
+	}
+
+	for _, node := range story.Nodes {
+		doc := searchDoc{
+			result: searchResult{Kind: "story", Key: string(node.ID), Title: node.Keyword, URL: "/story?keyword=" + url.QueryEscape(node.Keyword), titles: make(map[string]string)},
+			fields: []searchField{
+				{text: node.Keyword, weight: 4, title: true},
+				{text: node.Story, weight: 2, summary: true},
+			},
+		}
+		if ref, ok := resolvePageRef(config.Pages, node.Page); ok {
+			doc.result.Poster = config.Pages[ref].PosterPath
+		} else if len(node.Images) > 0 {
+			doc.result.Poster = node.Images[0]
+		}
+		for locale, t := range node.Translations {
+			if t.Keyword != "" {
+				doc.result.titles[locale] = t.Keyword
+			}
+			doc.fields = append(doc.fields, searchField{text: t.Keyword, weight: 4, title: true}, searchField{text: t.Story, weight: 2})
+		}
+		index.add(doc)
+	}
+
+	for term := range index.terms {
+		index.words = append(index.words, term)
+	}
+	sort.Strings(index.words)
+	return index
+}
+
+// add indexes the fields of doc
+func (index *searchIndex) add(doc searchDoc) {
+	d := len(index.docs)
+	index.docs = append(index.docs, doc)
+	for f, field := range doc.fields {
+		counts := make(map[string]int)
+		for _, token := range tokenize(field.text) {
+			counts[token.term]++
+		}
+		for term, count := range counts {
+			index.terms[term] = append(index.terms[term], searchPosting{d, f, count})
+		}
+	}
+}
+
+// matches returns the indexed terms matching a query term, exactly or as a prefix of them,
+// with the weight of the match
+func (index *searchIndex) matches(q string) map[string]float64 {
+	matches := make(map[string]float64)
+	for i := sort.SearchStrings(index.words, q); i < len(index.words) && strings.HasPrefix(index.words[i], q); i++ {
+		if index.words[i] == q {
+			matches[q] = 1
+		} else {
+			matches[index.words[i]] = prefixMatchWeight
+		}
+	}
+	return matches
+}
+
+// search returns the documents containing every term of query, best first. A term matches
+// the words it is a prefix of as well, so results show up while the query is being typed.
+func (index *searchIndex) search(query string, limit int) []searchResult {
+	var queryTerms []string
+	for _, token := range tokenize(query) {
+		queryTerms = append(queryTerms, token.term)
+	}
+	if len(queryTerms) == 0 {
+		return nil
+	}
+
+	scores := make(map[int]float64)
+	fieldScores := make(map[[2]int]float64) // score by document and field, to pick the snippet
+	matched := make(map[string]bool)        // every matching term, to highlight
+	for i, q := range queryTerms {
+		termScores := make(map[int]float64)
+		for term, weight := range index.matches(q) {
+			matched[term] = true
+			for _, p := range index.terms[term] {
+				score := index.docs[p.doc].fields[p.field].weight * weight * (1 + math.Log(float64(p.count)))
+				termScores[p.doc] += score
+				fieldScores[[2]int{p.doc, p.field}] += score
+			}
+		}
+
+		// Documents must contain every term of the query
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+		for d := range scores {
+			if termScores[d] == 0 {
+				delete(scores, d)
+			} else {
+				scores[d] += termScores[d]
+			}
+		}
+	}
+
+	results := make([]searchResult, 0, len(scores))
+	for d, score := range scores {
+		result := index.docs[d].result
+		result.Score = math.Round(score*100) / 100
+		result.Snippet = index.snippet(d, fieldScores, matched)
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Title < results[j].Title
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// snippet returns the excerpt of the best matching field of a document other than its title,
+// or the start of its first descriptive field if only the title matched
+func (index *searchIndex) snippet(d int, fieldScores map[[2]int]float64, matched map[string]bool) string {
+	doc := index.docs[d]
+	best, bestScore := -1, 0.0
+	for f, field := range doc.fields {
+		if score := fieldScores[[2]int{d, f}]; !field.title && score > bestScore {
+			best, bestScore = f, score
+		}
+	}
+	if best < 0 {
+		// Only the title matched, so the result is introduced by its description, or else by
+		// any other text it has
+		for _, field := range doc.fields {
+			if field.summary && field.text != "" {
+				return highlight(field.text, nil)
+			}
+		}
+		for _, field := range doc.fields {
+			if !field.title && field.text != "" {
+				return highlight(field.text, nil)
+			}
+		}
+		return ""
+	}
+	return highlight(doc.fields[best].text, matched)
+}
+
+// highlight returns an HTML excerpt of text around its first matching term, with every
+// matching term wrapped in <mark>
+func highlight(text string, matched map[string]bool) string {
+	tokens := tokenize(text)
+	var marks []searchToken
+	for _, token := range tokens {
+		if matched[token.term] {
+			marks = append(marks, token)
+		}
+	}
+
+	// Start a little before the first match, at the beginning of a word
+	start := 0
+	if len(marks) > 0 {
+		for _, token := range tokens {
+			if utf8.RuneCountInString(text[token.start:marks[0].start]) <= snippetLength/4 {
+				start = token.start
+				break
+			}
+		}
+	}
+	end, n := len(text), 0
+	for i := range text[start:] {
+		if n == snippetLength {
+			end = start + i
+			break
+		}
+		n++
+	}
+
+	var buf strings.Builder
+	if start > 0 {
+		buf.WriteString("…")
+	}
+	pos := start
+	for _, mark := range marks {
+		if mark.start < pos || mark.end > end {
+			continue
+		}
+		buf.WriteString(html.EscapeString(text[pos:mark.start]))
+		buf.WriteString("<mark>" + html.EscapeString(text[mark.start:mark.end]) + "</mark>")
+		pos = mark.end
+	}
+	buf.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		buf.WriteString("…")
+	}
+	return buf.String()
+}
+
+// search handler for the search API, answering ?q= with the best matching models and story
+// nodes in the locale of the request
+func (s *site) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeAPIError(w, http.StatusBadRequest, "the q parameter is required")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeAPIError(w, http.StatusBadRequest, "limit must be a number between 1 and %d", maxSearchLimit)
+			return
+		}
+		limit = n
+	}
+
+	state := s.state.Load()
+	results := state.index.search(query, limit)
+
+	// Results link to the pages in the locale of the request
+	locale := requestLocale(r, state.config.Site)
+	prefix := localePrefix(state.config.Site, locale)
+	for i, result := range results {
+		if title, ok := result.titles[locale]; ok {
+			results[i].Title = title
+		}
+		if result.Kind == "story" {
+			results[i].URL = "/story?keyword=" + url.QueryEscape(results[i].Title)
+		}
+		results[i].URL = prefix + results[i].URL
+	}
+
+	setLocaleHeaders(w, locale)
+	writeJSON(w, http.StatusOK, searchResponse{Query: query, Results: results})
+}
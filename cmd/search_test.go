@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	var terms []string
+	for _, token := range tokenize("Stone-Age axe, 3D scan: 序章 of 2024") {
+		terms = append(terms, token.term)
+	}
+	expected := []string{"stone", "age", "axe", "3d", "scan", "序", "章", "of", "2024"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("Expected %v, got %v", expected, terms)
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	axe := testPage("Stone Axe")
+	axe.Description = "A polished tool found near the river."
+	axe.DesignerName = "Jane"
+	bowl := testPage("Clay Bowl")
+	bowl.Description = "A bowl shaped like a stone, with <b>markup</b> in it."
+	bowl.Specs = []Spec{{Label: "Height", Value: "12", Unit: "cm"}}
+	config := Config{Pages: map[string]PageConfig{"axe": axe, "bowl": bowl}}
+	story := StoryGraph{Nodes: []StoryNode{{ID: "1", Keyword: "Intro", Story: "The stones were carried here.", Page: "axe"}}}
+	index := newSearchIndex(config, story)
+
+	var titles []string
+	for _, result := range index.search("stone", 10) {
+		titles = append(titles, result.Title)
+	}
+	if expected := []string{"Stone Axe", "Clay Bowl", "Intro"}; !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Expected the title match first and the prefix match last, got %v", titles)
+	}
+
+	results := index.search("stone bowl", 10)
+	if len(results) != 1 || results[0].Key != "bowl" {
+		t.Fatalf("Expected only the page matching every term, got %+v", results)
+	}
+	if expected := "A <mark>bowl</mark> shaped like a <mark>stone</mark>, with &lt;b&gt;markup&lt;/b&gt; in it."; results[0].Snippet != expected {
+		t.Errorf("Expected the snippet %q, got %q", expected, results[0].Snippet)
+	}
+
+	// A result matching only in its title starts with its description
+	if results := index.search("axe", 10); len(results) != 1 || results[0].Snippet != "A polished tool found near the river." {
+		t.Errorf("Expected the description as the snippet of a title match, got %+v", results)
+	}
+	bowl.Description = ""
+	if results := newSearchIndex(Config{Pages: map[string]PageConfig{"bowl": bowl}}, StoryGraph{}).search("clay", 10); len(results) != 1 || results[0].Snippet != "Height: 12 cm" {
+		t.Errorf("Expected other text as the snippet of a page without a description, got %+v", results)
+	}
+
+	if results := index.search("height 12", 10); len(results) != 1 || results[0].Snippet != "<mark>Height</mark>: <mark>12</mark> cm" {
+		t.Errorf("Expected the specs to be searched, got %+v", results)
+	}
+	if results := index.search("intro", 10); len(results) != 1 || results[0].Kind != "story" || results[0].Poster != axe.PosterPath || results[0].Snippet != "The stones were carried here." {
+		t.Errorf("Expected the story node with the poster of its page, got %+v", results)
+	}
+	if results := index.search("stone", 1); len(results) != 1 {
+		t.Errorf("Expected the results to be limited, got %d", len(results))
+	}
+	if results := index.search("missing", 10); len(results) != 0 {
+		t.Errorf("Expected no results, got %+v", results)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	text := strings.Repeat("word ", 60) + "target " + strings.Repeat("word ", 60)
+	snippet := highlight(text, map[string]bool{"target": true})
+	if !strings.HasPrefix(snippet, "…word") || !strings.HasSuffix(snippet, "…") || !strings.Contains(snippet, "<mark>target</mark>") {
+		t.Errorf("Expected an excerpt around the match, got %q", snippet)
+	}
+	if n := len([]rune(strings.NewReplacer("<mark>", "", "</mark>", "", "…", "").Replace(snippet))); n != snippetLength {
+		t.Errorf("Expected %d characters of text, got %d", snippetLength, n)
+	}
+}
+
+func TestSearchAPI(t *testing.T) {
+	page := testPage("Stone Axe")
+	page.Translations = map[string]PageTranslation{"zh": {ModelName: "石斧"}}
+	server, s := newTestAPIServer(t, Config{Pages: map[string]PageConfig{"page1": page}})
+
+	for _, query := range []string{"", "?q=+", "?q=axe&limit=0", "?q=axe&limit=51", "?q=axe&limit=many"} {
+		if resp, body := doJSON(t, "GET", server.URL+"/api/search"+query, "", ""); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %q to be rejected, got %d %s", query, resp.StatusCode, body)
+		}
+	}
+
+	search := func(path string) searchResponse {
+		resp, body := doJSON(t, "GET", server.URL+path, "", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d %s", path, resp.StatusCode, body)
+		}
+		var response searchResponse
+		if err := json.Unmarshal([]byte(body), &response); err != nil {
+			t.Fatalf("Expected a JSON response, got %v", err)
+		}
+		return response
+	}
+
+	if response := search("/api/search?q=ax"); len(response.Results) != 1 || response.Results[0].URL != "/models/page1" || response.Results[0].Title != "Stone Axe" {
+		t.Errorf("Expected the page, got %+v", response)
+	}
+	if response := search("/zh/api/search?q=石"); len(response.Results) != 1 || response.Results[0].URL != "/zh/models/page1" || response.Results[0].Title != "石斧" {
+		t.Errorf("Expected the translated page, got %+v", response)
+	}
+
+	if _, body := doJSON(t, "GET", server.URL+"/zh/", "", ""); !strings.Contains(body, `id="search-overlay"`) || !strings.Contains(body, `placeholder="搜索模型和故事"`) {
+		t.Errorf("Expected the home page to show the search overlay, got %s", body)
+	}
+
+	// The index follows the config and story as they are reloaded
+	s.swap(Config{Pages: map[string]PageConfig{"page2": testPage("Clay Bowl")}})
+	s.swapStory(StoryGraph{Nodes: []StoryNode{{ID: "1", Keyword: "Clay"}}})
+	if response := search("/api/search?q=axe"); len(response.Results) != 0 {
+		t.Errorf("Expected the removed page not to be found, got %+v", response)
+	}
+	response := search("/api/search?q=clay")
+	if len(response.Results) != 2 || response.Results[1].URL != "/story?keyword=Clay" {
+		t.Errorf("Expected the new page and story node, got %+v", response)
+	}
+}
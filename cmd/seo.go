@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sitemapNamespace is the XML namespace of the sitemaps protocol
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// sitemapURL is one page listed in sitemap.xml
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapURLSet is the root element of sitemap.xml
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// modelEncodings maps model file extensions to their media types in the JSON-LD
+var modelEncodings = map[string]string{
+	".glb":  "model/gltf-binary",
+	".gltf": "model/gltf+json",
+	".usdz": "model/vnd.usdz+zip",
+}
+
+// joinURL appends the root-relative path p to the base URL of a site
+func joinURL(base string, p string) string {
+	return strings.TrimSuffix(base, "/") + p
+}
+
+// pageJSONLD returns the schema.org description of a page embedded in it as JSON-LD
+func pageJSONLD(site SiteConfig, key string, page PageConfig) (string, error) {
+	license := pageLicense(site, page)
+	model := map[string]any{
+		"@context": "https://schema.org",
+		"@type":    "3DModel",
+		"name":     page.ModelName,
+		"license":  license.URL,
+	}
+	if page.Description != "" {
+		model["description"] = page.Description
+	}
+	if u := site.AbsoluteURL("/models/" + pageSlug(key, page)); u != "" {
+		model["url"] = u
+	}
+
+	// Files are linked at the public URL of the site when it is known
+	link := func(p string) string {
+		if u := site.AbsoluteURL(p); u != "" {
+			return u
+		}
+		return p
+	}
+	if page.PosterPath != "" {
+		model["image"] = link(page.PosterPath)
+	}
+	var encodings []map[string]any
+	for _, p := range []string{page.ModelSrcPath, page.ModelIosSrcPath} {
+		if p == "" {
+			continue
+		}
+		encoding := map[string]any{"@type": "MediaObject", "contentUrl": link(p)}
+		if format, ok := modelEncodings[strings.ToLower(path.Ext(p))]; ok {
+			encoding["encodingFormat"] = format
+		}
+		encodings = append(encodings, encoding)
+	}
+	if len(encodings) > 0 {
+		model["encoding"] = encodings
+	}
+	if page.DesignerName != "" {
+		creator := map[string]any{"@type": "Person", "name": page.DesignerName}
+		if page.DesignerWebsite != "" {
+			creator["url"] = page.DesignerWebsite
+		}
+		model["creator"] = creator
+	}
+	if page.SourceURL != "" {
+		model["isBasedOn"] = page.SourceURL
+	}
+	if len(page.Contributors) > 0 {
+		var contributors []map[string]any
+		for _, c := range page.Contributors {
+			contributor := map[string]any{"@type": "Person", "name": c.Name}
+			if c.Role != "" {
+				contributor["jobTitle"] = c.Role
+			}
+			if c.Website != "" {
+				contributor["url"] = c.Website
+			}
+			contributors = append(contributors, contributor)
+		}
+		model["contributor"] = contributors
+	}
+	if page.AcquiredOn != "" {
+		model["additionalProperty"] = map[string]any{"@type": "PropertyValue", "name": "acquiredOn", "value": page.AcquiredOn}
+	}
+
+	// Marshal escapes <, > and &, so the JSON cannot close the script element it is embedded in
+	data, err := json.Marshal(model)
+	return string(data), err
+}
+
+// sitemapPaths returns the root-relative paths of every page of the site worth indexing, in
+// the locale of the site first, then under the prefix of every other locale
+func sitemapPaths(config Config) []string {
+	paths := []string{"/", "/story", "/credits"}
+	for _, key := range sortedPageKeys(config.Pages) {
+		paths = append(paths, "/models/"+pageSlug(key, config.Pages[key]))
+	}
+
+	var localized []string
+	for _, locale := range availableLocales() {
+		if prefix := localePrefix(config.Site, locale); prefix != "" {
+			for _, p := range paths {
+				localized = append(localized, prefix+p)
+			}
+		}
+	}
+	return append(paths, localized...)
+}
+
+// sitemapXML returns the sitemap of the site hosted at base, with root-relative URLs if base is ""
+func sitemapXML(config Config, base string) ([]byte, error) {
+	set := sitemapURLSet{Xmlns: sitemapNamespace}
+	for _, p := range sitemapPaths(config) {
+		set.URLs = append(set.URLs, sitemapURL{Loc: joinURL(base, p)})
+	}
+
+	data, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// robotsTxt returns the robots.txt of the site hosted at base, pointing crawlers at its
+// sitemap if base is known
+func robotsTxt(base string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("User-agent: *\nAllow: /\nDisallow: /api/\n")
+	if base != "" {
+		fmt.Fprintf(&buf, "\nSitemap: %s\n", joinURL(base, "/sitemap.xml"))
+	}
+	return buf.Bytes()
+}
+
+// sitemap handler for sitemap.xml, listing the pages at the BaseURL of the site or, without
+// one, at root-relative URLs, since the Host header of a request can be forged
+func (s *site) sitemap(w http.ResponseWriter, r *http.Request) {
+	config := s.state.Load().config
+	data, err := sitemapXML(config, config.Site.BaseURL)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write(data)
+}
+
+// robots handler for robots.txt, which only points at the sitemap when the BaseURL is set
+func (s *site) robots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(robotsTxt(s.state.Load().config.Site.BaseURL))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPageJSONLD(t *testing.T) {
+	page := testPage("Model 1")
+	page.DesignerName = "Designer"
+	page.License = "CC0-1.0"
+	page.SourceURL = "https://example.com/scans/1"
+	page.Contributors = []Contributor{{Name: "</script>", Role: "Photographer"}}
+	page.AcquiredOn = "2024-05-17"
+
+	data, err := pageJSONLD(SiteConfig{BaseURL: "https://example.com"}, "page1", page)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(data, "</script>") {
+		t.Fatalf("Expected the JSON-LD to be safe to embed in a script element, got %s", data)
+	}
+
+	var model map[string]any
+	if err := json.Unmarshal([]byte(data), &model); err != nil {
+		t.Fatalf("Expected valid JSON, got %v: %s", err, data)
+	}
+	expected := map[string]any{
+		"@type":     "3DModel",
+		"name":      "Model 1",
+		"license":   "https://creativecommons.org/publicdomain/zero/1.0/",
+		"url":       "https://example.com/models/page1",
+		"image":     "https://example.com/static/models/obj1/object1.webp",
+		"isBasedOn": "https://example.com/scans/1",
+	}
+	for key, value := range expected {
+		if model[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, model[key])
+		}
+	}
+	if contributors, ok := model["contributor"].([]any); !ok || len(contributors) != 1 {
+		t.Errorf("Expected one contributor, got %v", model["contributor"])
+	}
+
+	encodings, _ := model["encoding"].([]any)
+	var formats []string
+	for _, e := range encodings {
+		formats = append(formats, e.(map[string]any)["encodingFormat"].(string))
+	}
+	if !reflect.DeepEqual(formats, []string{"model/gltf-binary", "model/vnd.usdz+zip"}) {
+		t.Errorf("Expected the glb and usdz encodings, got %v", model["encoding"])
+	}
+
+	// Without a BaseURL the files are linked by path
+	data, _ = pageJSONLD(SiteConfig{}, "page1", page)
+	if !strings.Contains(data, `"image":"/static/models/obj1/object1.webp"`) || strings.Contains(data, `"url"`) {
+		t.Errorf("Expected root-relative links without a BaseURL, got %s", data)
+	}
+}
+
+func TestRenderOpenGraph(t *testing.T) {
+	page := testPage("Model \"1\"")
+	page.Description = "A stone & its dust"
+	config := Config{Site: SiteConfig{Title: "Museum", BaseURL: "https://example.com"}, Pages: map[string]PageConfig{"page1": page}}
+
+	var buf strings.Builder
+	if err := renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page1", "card"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	html := buf.String()
+	for _, expected := range []string{
+		`<title>Model &#34;1&#34; - Museum</title>`,
+		`<meta property="og:title" content="Model &#34;1&#34;">`,
+		`<meta property="og:description" content="A stone &amp; its dust">`,
+		`<meta name="description" content="A stone &amp; its dust">`,
+		`<meta property="og:url" content="https://example.com/models/page1">`,
+		`<meta property="og:image" content="https://example.com/static/models/obj1/object1.webp">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<meta property="og:site_name" content="Museum">`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected page to contain %s, got %s", expected, html)
+		}
+	}
+
+	if strings.Contains(html, "&amp;amp;") {
+		t.Errorf("Expected text to be escaped once, got %s", html)
+	}
+
+	// A name closing the title cannot inject markup
+	config.Pages["page2"] = testPage("</title><script>alert(1)</script>")
+	buf.Reset()
+	renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page2", "card")
+	if html := buf.String(); strings.Contains(html, "<script>alert(1)") || !strings.Contains(html, "<title>&lt;/title&gt;&lt;script&gt;alert(1)&lt;/script&gt; - Museum</title>") {
+		t.Errorf("Expected the title to be escaped, got %s", html)
+	}
+	delete(config.Pages, "page2")
+
+	buf.Reset()
+	renderPage(&buf, parseTemplates(), Config{Pages: config.Pages}, StoryGraph{}, "page1", "plain")
+	if html := buf.String(); strings.Contains(html, "og:image") || !strings.Contains(html, `<meta name="twitter:card" content="summary">`) {
+		t.Errorf("Expected no image preview without a BaseURL, got %s", html)
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	page := testPage("Model 2")
+	page.Slug = "second"
+	config := Config{Pages: map[string]PageConfig{"page1": testPage("Model 1"), "page2": page}}
+	server, s := newTestAPIServer(t, config)
+
+	resp, body := doJSON(t, "GET", server.URL+"/sitemap.xml", "", "")
+	if resp.Header.Get("Content-Type") != "application/xml; charset=utf-8" {
+		t.Errorf("Expected an XML response, got %s", resp.Header.Get("Content-Type"))
+	}
+	var set sitemapURLSet
+	if err := xml.Unmarshal([]byte(body), &set); err != nil {
+		t.Fatalf("Expected a valid sitemap, got %v: %s", err, body)
+	}
+	var locs []string
+	for _, u := range set.URLs {
+		locs = append(locs, u.Loc)
+	}
+	var expected []string
+	for _, prefix := range []string{"", "/zh"} {
+		for _, p := range []string{"/", "/story", "/credits", "/models/page1", "/models/second"} {
+			expected = append(expected, prefix+p)
+		}
+	}
+	if !reflect.DeepEqual(locs, expected) {
+		t.Fatalf("Expected sitemap %v, got %v", expected, locs)
+	}
+
+	// Crawlers need an absolute sitemap URL, which the Host header of a request cannot be trusted for
+	if _, body := doJSON(t, "GET", server.URL+"/robots.txt", "", ""); strings.Contains(body, "Sitemap") {
+		t.Errorf("Expected robots.txt without a sitemap, got %s", body)
+	}
+	req, _ := http.NewRequest("GET", server.URL+"/sitemap.xml", nil)
+	req.Host = "evil.example"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if strings.Contains(string(data), "evil.example") {
+		t.Errorf("Expected the sitemap to ignore the Host header, got %s", data)
+	}
+
+	config.Site.BaseURL = "https://example.com/museum/"
+	s.swap(config)
+	if _, body := doJSON(t, "GET", server.URL+"/sitemap.xml", "", ""); !strings.Contains(body, "<loc>https://example.com/museum/models/second</loc>") {
+		t.Errorf("Expected the sitemap to use the BaseURL, got %s", body)
+	}
+	if _, body := doJSON(t, "GET", server.URL+"/robots.txt", "", ""); !strings.Contains(body, "Sitemap: https://example.com/museum/sitemap.xml") {
+		t.Errorf("Expected robots.txt to point at the sitemap, got %s", body)
+	}
+}
+
+func TestBuildSitemap(t *testing.T) {
+	useTestUIFiles(t)
+	outDir := t.TempDir()
+	config := Config{Site: SiteConfig{BaseURL: "https://example.com"}, Pages: map[string]PageConfig{"page1": testPage("Model 1")}}
+
+	if err := buildSite(config, StoryGraph{}, parseTemplates(), "card", outDir); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	sitemap, err := os.ReadFile(filepath.Join(outDir, "sitemap.xml"))
+	if err != nil || !strings.Contains(string(sitemap), "<loc>https://example.com/models/page1</loc>") {
+		t.Errorf("Expected sitemap.xml to list the pages, got %s (%v)", sitemap, err)
+	}
+	robots, err := os.ReadFile(filepath.Join(outDir, "robots.txt"))
+	if err != nil || !strings.Contains(string(robots), "Sitemap: https://example.com/sitemap.xml") {
+		t.Errorf("Expected robots.txt to point at the sitemap, got %s (%v)", robots, err)
+	}
+
+	// Without a BaseURL there is no sitemap to point at
+	outDir = t.TempDir()
+	config.Site.BaseURL = ""
+	if err := buildSite(config, StoryGraph{}, parseTemplates(), "card", outDir); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "sitemap.xml")); !os.IsNotExist(err) {
+		t.Errorf("Expected no sitemap.xml without a BaseURL")
+	}
+	if robots, _ := os.ReadFile(filepath.Join(outDir, "robots.txt")); strings.Contains(string(robots), "Sitemap") {
+		t.Errorf("Expected robots.txt without a sitemap, got %s", robots)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"sync/atomic"
+)
+
+// SiteConfig holds the settings shared by every page of the site. Layout and Port are the
+// defaults of the --layout and --port flags, which override them when given.
+type SiteConfig struct {
+	Title       string `yaml:"Title,omitempty"`
+	Description string `yaml:"Description,omitempty"`
+	Footer      string `yaml:"Footer,omitempty"`    // copyright line of the page footers
+	License     string `yaml:"License,omitempty"`   // URL of the licence the models are shared under
+	Analytics   *bool  `yaml:"Analytics,omitempty"` // loads the third-party Buy Me a Coffee widget, on unless false
+	Layout      string `yaml:"Layout,omitempty"`
+	Port        int    `yaml:"Port,omitempty"`
+	BaseURL     string `yaml:"BaseURL,omitempty"` // public URL the site is hosted at, e.g. https://example.com/sack
+	Locale      string `yaml:"Locale,omitempty"`  // locale the text of the config is written in, en unless set
+}
+
+// defaultSiteTitle names the site in page titles unless Title is set
+const defaultSiteTitle = "Sack"
+
+// defaultLicense is the licence linked from the model pages unless License is set
+const defaultLicense = "https://creativecommons.org/licenses/by/2.0/"
+
+// SiteTitle returns the title of the site
+func (s SiteConfig) SiteTitle() string {
+	if s.Title != "" {
+		return s.Title
+	}
+	return defaultSiteTitle
+}
+
+// FooterText returns the footer with entities such as &copy; decoded. Any markup is shown as
+// written, so the footer cannot add scripts or links to the pages.
+func (s SiteConfig) FooterText() string {
+	return html.UnescapeString(s.Footer)
+}
+
+// LicenseURL returns the URL of the licence the models are shared under
+func (s SiteConfig) LicenseURL() string {
+	if s.License != "" {
+		return s.License
+	}
+	return defaultLicense
+}
+
+// AbsoluteURL returns the public URL of the root-relative path p, or "" if BaseURL is not set
+func (s SiteConfig) AbsoluteURL(p string) string {
+	if s.BaseURL == "" {
+		return ""
+	}
+	return joinURL(s.BaseURL, p)
+}
+
+// ShowAnalytics reports whether the third-party widgets are loaded
+func (s SiteConfig) ShowAnalytics() bool {
+	return s.Analytics == nil || *s.Analytics
+}
+
+// validateSite checks the Site block of the config, returning every invalid field
+func validateSite(site SiteConfig) []fieldError {
+	var errs []fieldError
+	for _, field := range []struct{ name, value string }{{"License", site.License}, {"BaseURL", site.BaseURL}} {
+		if field.value == "" {
+			continue
+		}
+		if !isHTTPURL(field.value) {
+			errs = append(errs, fieldError{Field: field.name, Message: fmt.Sprintf("%q is not a valid http(s) URL", field.value)})
+		}
+	}
+	if msg := checkLayout(site.Layout); msg != "" {
+		errs = append(errs, fieldError{Field: "Layout", Message: msg})
+	}
+	if site.Locale != "" {
+		if msg := checkLocale(site.Locale); msg != "" {
+			errs = append(errs, fieldError{Field: "Locale", Message: msg})
+		}
+	}
+	if site.Port < 0 || site.Port > 65535 {
+		errs = append(errs, fieldError{Field: "Port", Message: "must be between 1 and 65535"})
+	}
+	return errs
+}
+
+// useSiteDefaults sets the flags of fs that were not given on the command line from the Site
+// block of the config
+func useSiteDefaults(fs *flag.FlagSet, site SiteConfig) {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	defaults := map[string]string{"layout": site.Layout}
+	if site.Port != 0 {
+		defaults["port"] = strconv.Itoa(site.Port)
+	}
+	for name, value := range defaults {
+		if value == "" || given[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			log.Fatalf("Invalid Site setting for --%s: %s", name, err)
+		}
+	}
+}
+
+// servedSettings holds the Site block of the config being served, for the pages rendered
+// outside of a site such as the error pages
+var servedSettings atomic.Pointer[SiteConfig]
+
+// siteSettings returns the Site block of the config being served
+func siteSettings() SiteConfig {
+	if settings := servedSettings.Load(); settings != nil {
+		return *settings
+	}
+	return SiteConfig{}
+}
+
+// standaloneData holds the values passed to the standalone pages: home, story and error pages
+type standaloneData struct {
+	localization
+	Site    SiteConfig
+	Story   *StoryGraph    // part of the story embedded in the story page, when filtered by keyword
+	Credits []modelCredits // attribution of every model, listed on the credits page
+	Search  bool           // the page is served along with /api/search, so it shows the search overlay
+	Detail  string         // what went wrong, shown on the error pages in dev mode
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUseSiteDefaults(t *testing.T) {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	port := fs.Int("port", 7536, "")
+	layout := fs.String("layout", "card", "")
+	fs.Parse([]string{"--layout", "card"})
+
+	useSiteDefaults(fs, SiteConfig{Port: 8080, Layout: "plain"})
+	if *port != 8080 {
+		t.Errorf("Expected Site.Port to replace the default port, got %d", *port)
+	}
+	if *layout != "card" {
+		t.Errorf("Expected --layout to override Site.Layout, got %s", *layout)
+	}
+}
+
+func TestValidateSiteConfig(t *testing.T) {
+	useTestUIFiles(t)
+	configData := `Site:
+  Title: "Museum"
+  Licence: "https://creativecommons.org/licenses/by/4.0/"
+  BaseURL: "example.com"
+  Layout: "grid"
+  Port: 70000
+Pages:
+  page1:
+    ModelSrcPath: "/static/models/obj1/object1.glb"
+    ModelIosSrcPath: "/static/models/obj1/object1.usdz"
+    PosterPath: "/static/models/obj1/object1.webp"
+    ModelName: "Object 1"
+`
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(filename, []byte(configData), 0644)
+
+	problems := validateConfigFile(filename)
+	expected := []string{
+		":3:3: Site: unknown field Licence",
+		`:4:12: Site: BaseURL "example.com" is not a valid http(s) URL`,
+		`:5:11: Site: Layout "grid" is not one of the available layouts (card, plain)`,
+		":6:9: Site: Port must be between 1 and 65535",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got %v", len(expected), problems)
+	}
+	for i, p := range problems {
+		if !strings.HasSuffix(p.String(), expected[i]) {
+			t.Errorf("Expected problem %q, got %q", expected[i], p.String())
+		}
+	}
+}
+
+func TestRenderSiteSettings(t *testing.T) {
+	analytics := false
+	config := Config{
+		Site: SiteConfig{
+			Title:       "Museum",
+			Description: "Finds from the dig",
+			Footer:      "&copy 2026 The Museum<script>alert(1)</script>",
+			License:     "https://creativecommons.org/licenses/by-sa/4.0/",
+			Analytics:   &analytics,
+			BaseURL:     "https://example.com/museum/",
+		},
+		Pages: map[string]PageConfig{"page1": testPage("Model 1")},
+	}
+
+	var buf strings.Builder
+	if err := renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page1", "card"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	html := buf.String()
+	for _, expected := range []string{
+		"<title>Model 1 - Museum</title>",
+		`<meta name="description" content="Finds from the dig">`,
+		`<link rel="canonical" href="https://example.com/museum/models/page1">`,
+		"© 2026 The Museum&lt;script&gt;alert(1)&lt;/script&gt;",
+		`href="https://creativecommons.org/licenses/by-sa/4.0/"`,
+		"icons/sa.svg",
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected page to contain %s, got %s", expected, html)
+		}
+	}
+	if strings.Contains(html, "BMC-Widget") || strings.Contains(html, "Lemorage") {
+		t.Errorf("Expected the widget and default footer to be left out")
+	}
+
+	// Without a Site block, the pages look as they always did
+	buf.Reset()
+	renderPage(&buf, parseTemplates(), Config{Pages: config.Pages}, StoryGraph{}, "page1", "plain")
+	if html := buf.String(); !strings.Contains(html, "<title>Model 1 - Sack</title>") || !strings.Contains(html, "BMC-Widget") || strings.Contains(html, "canonical") {
+		t.Errorf("Expected the default settings, got %s", html)
+	}
+}
+
+func TestStandalonePageSettings(t *testing.T) {
+	server, _ := newTestAPIServer(t, Config{Site: SiteConfig{Title: "Museum"}, Pages: map[string]PageConfig{"page1": testPage("Model 1")}})
+
+	for url, expected := range map[string]string{
+		"/":        "<title>Museum</title>",
+		"/story":   "<title>Museum - Storytelling</title>",
+		"/missing": "<title>Page Not Found - Museum</title>",
+	} {
+		if _, body := doJSON(t, "GET", server.URL+url, "", ""); !strings.Contains(body, expected) {
+			t.Errorf("Expected %s to contain %s, got %s", url, expected, body)
+		}
+	}
+}
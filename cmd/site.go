@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// legacyPagePattern matches the former /modelN page routes
+var legacyPagePattern = regexp.MustCompile(`^model(\d+)$`)
+
+// siteState is a snapshot of the configuration the site serves, swapped as a whole on reload
+type siteState struct {
+	config Config
+	story  StoryGraph
+	slugs  map[string]string // page key by slug
+	tmpl   *template.Template
+	index  *searchIndex // text of the pages and story, searched through /api/search
+}
+
+// site holds the configuration and templates the server renders model pages from
+type site struct {
+	state  atomic.Pointer[siteState]
+	layout string
+	editMu sync.Mutex // serializes edits made through the API
+
+	// In live mode pages are rendered on request and cached until a watched file changes
+	live  bool
+	mu    sync.Mutex
+	stale bool
+	cache map[string][]byte
+}
+
+// newSite creates a site serving the pages of config and the story linking them with the
+// given templates and layout
+func newSite(config Config, story StoryGraph, tmpl *template.Template, layout string, live bool) (*site, error) {
+	slugs, err := pageKeysBySlug(config.Pages)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &site{layout: layout, live: live, cache: make(map[string][]byte)}
+	s.state.Store(&siteState{config: config, story: story, slugs: slugs, tmpl: tmpl, index: newSearchIndex(config, story)})
+	servedSettings.Store(&config.Site)
+	return s, nil
+}
+
+// handleChange reacts to a changed file: the config file and story graph are reloaded right
+// away, while any other change drops the rendered pages and message catalogs. It returns what the open pages should
+// do about the change, or why a reload was rejected.
+func (s *site) handleChange(path string) (reloadMessage, error) {
+	switch {
+	case isSamePath(path, configPath):
+		return s.reloadConfig()
+	case isSamePath(path, storyGraphPath):
+		return reloadMessage{Type: reloadAll, Paths: []string{path}}, s.reloadStory()
+	}
+	dropCatalogs()
+	s.invalidate()
+	return fileChangeMessage(path), nil
+}
+
+// reloadConfig reads, validates and swaps in the config file, keeping the running
+// configuration if the new one has any problem. Unless the change reaches beyond some pages,
+// only the open copies of those need to reload.
+func (s *site) reloadConfig() (reloadMessage, error) {
+	// The server starts without the model files too, so those not added yet are only warnings
+	problems, missing := splitMissing(validateConfigFile(configPath))
+	if len(problems) > 0 {
+		return reloadMessage{}, problemsError(problems)
+	}
+	logMissing(missing)
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		return reloadMessage{}, err
+	}
+	previous := s.state.Load().config
+	if err := s.swap(config); err != nil {
+		return reloadMessage{}, err
+	}
+
+	// A file that changed as it was saved through the API has been served already, leaving
+	// nothing to compare, so the open pages are reloaded as a whole
+	if slugs, ok := changedPages(previous, config); ok && len(slugs) > 0 {
+		return reloadMessage{Type: reloadPages, Paths: []string{configPath}, Pages: slugs}, nil
+	}
+	return reloadMessage{Type: reloadAll, Paths: []string{configPath}}, nil
+}
+
+// changedPages returns the slugs of the pages that differ between two configs, or false if
+// the change also shows on the other pages: a page added, removed, moved or renamed in the
+// navigation, or a change to the Site block
+func changedPages(previous Config, config Config) ([]string, bool) {
+	if !reflect.DeepEqual(previous.Site, config.Site) || !reflect.DeepEqual(sortedPageKeys(previous.Pages), sortedPageKeys(config.Pages)) {
+		return nil, false
+	}
+
+	slugs := []string{}
+	for _, key := range sortedPageKeys(config.Pages) {
+		before, page := previous.Pages[key], config.Pages[key]
+		if pageSlug(key, before) != pageSlug(key, page) {
+			return nil, false
+		}
+		if !reflect.DeepEqual(before, page) {
+			slugs = append(slugs, pageSlug(key, page))
+		}
+	}
+	return slugs, true
+}
+
+// reloadStory reads, validates and swaps in the story graph, keeping the running one if
+// the new one has any problem
+func (s *site) reloadStory() error {
+	if problems := validateGraphFile(storyGraphPath, s.state.Load().config.Pages); len(problems) > 0 {
+		return problemsError(problems)
+	}
+
+	story, err := readStoryGraph(storyGraphPath)
+	if err != nil {
+		return err
+	}
+	return s.swapStory(story)
+}
+
+// logMissing logs the problems about files not found under ui/static as warnings
+func logMissing(missing []problem) {
+	for _, p := range missing {
+		log.Printf("%swarning: %s%s", Yellow, p, Reset)
+	}
+}
+
+// swap atomically replaces the served configuration, rebuilding the route table and pages
+func (s *site) swap(config Config) error {
+	if err := s.replace(config, s.state.Load().story); err != nil {
+		return err
+	}
+	log.Printf("%sReloaded config with %d page(s)%s", Cyan, len(config.Pages), Reset)
+	return nil
+}
+
+// swapStory atomically replaces the served story graph, rebuilding the pages it appears on
+func (s *site) swapStory(story StoryGraph) error {
+	if err := s.replace(s.state.Load().config, story); err != nil {
+		return err
+	}
+	log.Printf("%sReloaded story with %d node(s)%s", Cyan, len(story.Nodes), Reset)
+	return nil
+}
+
+// replace stores a new configuration and story graph, writing the pages again unless they
+// are rendered on request and rebuilding the search index
+func (s *site) replace(config Config, story StoryGraph) error {
+	slugs, err := pageKeysBySlug(config.Pages)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.state.Load()
+	if !s.live {
+		if err := writeHTMLFiles(config, story, current.tmpl, s.layout); err != nil {
+			return err
+		}
+	}
+
+	s.state.Store(&siteState{config: config, story: story, slugs: slugs, tmpl: current.tmpl, index: newSearchIndex(config, story)})
+	servedSettings.Store(&config.Site)
+	s.cache = make(map[string][]byte)
+	return nil
+}
+
+// invalidate drops the rendered pages so they are rebuilt from the templates on the next request
+func (s *site) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.live {
+		s.stale = true
+		s.cache = make(map[string][]byte)
+	}
+}
+
+// refresh re-parses the templates after a file change, keeping the previous ones if they
+// can no longer be loaded. The caller must hold s.mu.
+func (s *site) refresh() {
+	if !s.stale {
+		return
+	}
+	s.stale = false
+
+	tmpl, err := loadTemplates()
+	if err != nil {
+		log.Printf("%sError reloading templates, keeping the previous ones: %s%s", Red, err, Reset)
+		return
+	}
+
+	current := s.state.Load()
+	s.state.Store(&siteState{config: current.config, story: current.story, slugs: current.slugs, tmpl: tmpl, index: current.index})
+}
+
+// render returns the HTML of the page with the given slug in locale, rendering it if it is not cached
+func (s *site) render(slug string, locale string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh()
+	state := s.state.Load()
+	key, ok := state.slugs[slug]
+	if !ok {
+		return nil, false, nil
+	}
+	cacheKey := locale + "/" + slug
+	if html, ok := s.cache[cacheKey]; ok {
+		return html, true, nil
+	}
+
+	var buf bytes.Buffer
+	if err := renderLocalizedPage(&buf, state.tmpl, state.config, state.story, key, s.layout, locale); err != nil {
+		return nil, true, fmt.Errorf("rendering page %s: %w", key, err)
+	}
+	s.cache[cacheKey] = buf.Bytes()
+	return buf.Bytes(), true, nil
+}
+
+// servePage serves the model page whose slug is in the request path. Outside of live mode
+// only the pages in the locale of the site are written to files, the others are rendered on request.
+func (s *site) servePage(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	locale := requestLocale(r, s.state.Load().config.Site)
+	setLocaleHeaders(w, locale)
+
+	if !s.live && locale == s.state.Load().config.Site.SiteLocale() {
+		if _, ok := s.state.Load().slugs[slug]; !ok {
+			notFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, fmt.Sprintf("./ui/html/pages/%s.gohtml", slug))
+		return
+	}
+
+	html, ok, err := s.render(slug, locale)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	if !ok {
+		notFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(html)
+}
+
+// serveLegacyPage permanently redirects the former /modelN routes to the page's slug, and a
+// locale prefix missing its trailing slash to the home page of the locale
+func (s *site) serveLegacyPage(w http.ResponseWriter, r *http.Request) {
+	legacy := r.PathValue("legacy")
+	if _, prefixed := r.Context().Value(localeKey{}).(string); !prefixed && isAvailableLocale(legacy) {
+		http.Redirect(w, r, "/"+legacy+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	match := legacyPagePattern.FindStringSubmatch(legacy)
+	if match == nil {
+		notFound(w, r)
+		return
+	}
+	number, _ := strconv.Atoi(match[1])
+
+	config := s.state.Load().config
+	key, ok := legacyPageNumbers(config.Pages)[number]
+	if !ok {
+		notFound(w, r)
+		return
+	}
+
+	target := "/models/" + pageSlug(key, config.Pages[key])
+	if locale, ok := r.Context().Value(localeKey{}).(string); ok {
+		target = "/" + locale + target
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+// isSamePath reports whether two paths refer to the same file
+func isSamePath(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
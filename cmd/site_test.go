@@ -0,0 +1,119 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPage returns a page passing validation against the files of useTestUIFiles
+func testPage(name string) PageConfig {
+	return PageConfig{
+		ModelSrcPath:    "/static/models/obj1/object1.glb",
+		ModelIosSrcPath: "/static/models/obj1/object1.usdz",
+		PosterPath:      "/static/models/obj1/object1.webp",
+		ModelName:       name,
+	}
+}
+
+func TestLiveSite(t *testing.T) {
+	useTestUIFiles(t)
+	original := configPath
+	configPath = filepath.Join(t.TempDir(), "config.yaml")
+	defer func() { configPath = original }()
+
+	config := Config{
+		Pages: map[string]PageConfig{
+			"page1": testPage("Model 1"),
+		},
+	}
+	writeConfig(configPath, config)
+
+	tmpl := template.Must(template.New("base").Parse("Page: {{.PageConfig.ModelName}}"))
+	s, err := newSite(config, StoryGraph{}, tmpl, "card", true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	server := httptest.NewServer(setupHandlers(s))
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := get("/models/page1"); status != http.StatusOK || body != "Page: Model 1" {
+		t.Fatalf("Expected rendered page, got %d %q", status, body)
+	}
+	if status, _ := get("/models/page2"); status != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", status)
+	}
+
+	// Add a page and rename the first one, as an editor would while the server runs
+	config = Config{Pages: map[string]PageConfig{"page1": testPage("Renamed"), "page2": testPage("Model 2")}}
+	writeConfig(configPath, config)
+	message, err := s.handleChange(configPath)
+	if err != nil {
+		t.Fatalf("Expected config to be reloaded, got %v", err)
+	}
+	if message.Type != reloadAll {
+		t.Errorf("Expected a new page to reload every page, got %+v", message)
+	}
+
+	if status, body := get("/models/page1"); status != http.StatusOK || !strings.Contains(body, "Renamed") {
+		t.Fatalf("Expected re-rendered page, got %d %q", status, body)
+	}
+	if status, body := get("/models/page2"); status != http.StatusOK || !strings.Contains(body, "Model 2") {
+		t.Fatalf("Expected new page to be served, got %d %q", status, body)
+	}
+
+	// Editing a page only reloads that page
+	config.Pages["page2"] = testPage("Model 2 edited")
+	writeConfig(configPath, config)
+	if message, err := s.handleChange(configPath); err != nil || message.Type != reloadPages || !reflect.DeepEqual(message.Pages, []string{"page2"}) {
+		t.Errorf("Expected only page2 to reload, got %+v %v", message, err)
+	}
+
+	// A rejected reload keeps serving the previous configuration
+	config.Pages["page3"] = PageConfig{ModelName: "Incomplete"}
+	writeConfig(configPath, config)
+	if _, err := s.handleChange(configPath); err == nil {
+		t.Fatal("Expected invalid config to be rejected")
+	}
+	os.WriteFile(configPath, []byte("Pages: ["), 0644)
+	if _, err := s.handleChange(configPath); err == nil {
+		t.Fatal("Expected malformed config to be rejected")
+	}
+	if status, _ := get("/models/page2"); status != http.StatusOK {
+		t.Fatalf("Expected previous config to be kept after a rejected reload, got %d", status)
+	}
+	if status, _ := get("/models/page3"); status != http.StatusNotFound {
+		t.Fatalf("Expected rejected page to be missing, got %d", status)
+	}
+
+	// Model files not added yet are accepted, as they are at startup
+	config.Pages["page3"] = PageConfig{
+		ModelSrcPath:    "/static/models/obj3/object3.glb",
+		ModelIosSrcPath: "/static/models/obj3/object3.usdz",
+		PosterPath:      "/static/models/obj3/object3.webp",
+		ModelName:       "Model 3",
+	}
+	writeConfig(configPath, config)
+	if _, err := s.handleChange(configPath); err != nil {
+		t.Fatalf("Expected missing model files to be a warning, got %v", err)
+	}
+	if status, body := get("/models/page3"); status != http.StatusOK || !strings.Contains(body, "Model 3") {
+		t.Fatalf("Expected page with missing model files to be served, got %d %q", status, body)
+	}
+}
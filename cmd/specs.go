@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Spec is a physical property of a model shown in its info bubble, e.g. Body mass: 9.3 g.
+// Specs with a unit hold one number, or several for dimensions such as 32 x 24 x 13, and
+// are shown in both metric and imperial units; specs without a unit are shown as written.
+type Spec struct {
+	Label string `yaml:"Label"`
+	Value string `yaml:"Value"`
+	Unit  string `yaml:"Unit,omitempty"`
+}
+
+// unit describes a unit of measurement and how to express it in the other system
+type unit struct {
+	metric bool
+	other  string  // unit of the other system values are converted to
+	factor float64 // multiplier converting a value into the other unit
+}
+
+// units lists the supported units of measurement
+var units = map[string]unit{
+	"mg":    {true, "gr", 0.0154324},
+	"g":     {true, "oz", 0.0352740},
+	"kg":    {true, "lb", 2.2046226},
+	"mm":    {true, "in", 1 / 25.4},
+	"cm":    {true, "in", 1 / 2.54},
+	"m":     {true, "ft", 3.2808399},
+	"ml":    {true, "fl oz", 0.0338140},
+	"l":     {true, "gal", 0.2641720},
+	"gr":    {false, "mg", 64.79891},
+	"oz":    {false, "g", 28.349523},
+	"lb":    {false, "kg", 0.4535924},
+	"in":    {false, "mm", 25.4},
+	"ft":    {false, "m", 0.3048},
+	"fl oz": {false, "ml", 29.573530},
+	"gal":   {false, "l", 3.7854118},
+}
+
+// specSeparator splits the numbers of a dimension value such as 32 x 24 x 13
+var specSeparator = regexp.MustCompile(`\s*[x×*]\s*`)
+
+// numbers parses the value of a spec with a unit into its numbers
+func (s Spec) numbers() ([]float64, error) {
+	var numbers []float64
+	for _, part := range specSeparator.Split(strings.TrimSpace(s.Value), -1) {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, fmt.Errorf("%q must be a number, or numbers separated by x for dimensions", s.Value)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// Metric returns the spec in metric units
+func (s Spec) Metric() string {
+	return s.in(true)
+}
+
+// Imperial returns the spec in imperial units
+func (s Spec) Imperial() string {
+	return s.in(false)
+}
+
+// in formats the spec in the metric or imperial system, converting it if needed
+func (s Spec) in(metric bool) string {
+	u, ok := units[s.Unit]
+	if !ok {
+		return strings.TrimSpace(s.Value + " " + s.Unit)
+	}
+	numbers, err := s.numbers()
+	if err != nil {
+		return s.Value + " " + s.Unit
+	}
+
+	name, factor := s.Unit, 1.0
+	if u.metric != metric {
+		name, factor = u.other, u.factor
+	}
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = formatSpecNumber(n * factor)
+	}
+	return strings.Join(parts, " × ") + " " + name
+}
+
+// formatSpecNumber formats a converted value with at most two decimals
+func formatSpecNumber(n float64) string {
+	return strconv.FormatFloat(math.Round(n*100)/100, 'f', -1, 64)
+}
+
+// validateSpec checks a single spec, returning every invalid field
+func validateSpec(spec Spec) []fieldError {
+	var errs []fieldError
+	if strings.TrimSpace(spec.Label) == "" {
+		errs = append(errs, fieldError{Field: "Label", Message: "is required"})
+	}
+	if strings.TrimSpace(spec.Value) == "" {
+		errs = append(errs, fieldError{Field: "Value", Message: "is required"})
+	} else if spec.Unit != "" {
+		if _, err := spec.numbers(); err != nil {
+			errs = append(errs, fieldError{Field: "Value", Message: err.Error()})
+		}
+	}
+	if _, ok := units[spec.Unit]; spec.Unit != "" && !ok {
+		errs = append(errs, fieldError{Field: "Unit", Message: fmt.Sprintf("%q is not one of %s", spec.Unit, strings.Join(unitNames(), ", "))})
+	}
+	return errs
+}
+
+// unitNames returns the supported units, sorted
+func unitNames() []string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSpec reads a spec written as Label=Value Unit, e.g. "Dimensions=32 x 24 x 13 mm".
+// The unit is optional, values without a known unit are kept as text.
+func parseSpec(input string) (Spec, error) {
+	label, value, ok := strings.Cut(input, "=")
+	if !ok {
+		return Spec{}, fmt.Errorf("expected Label=Value [Unit], got %q", input)
+	}
+	spec := Spec{Label: strings.TrimSpace(label), Value: strings.TrimSpace(value)}
+
+	// Try the longest unit first so "fl oz" is not read as "oz"
+	names := unitNames()
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		if rest, ok := strings.CutSuffix(spec.Value, name); ok {
+			candidate := Spec{Label: spec.Label, Value: strings.TrimSpace(rest), Unit: name}
+			if _, err := candidate.numbers(); err == nil {
+				spec = candidate
+				break
+			}
+		}
+	}
+
+	if errs := validateSpec(spec); len(errs) > 0 {
+		return spec, fmt.Errorf("%s %s", errs[0].Field, errs[0].Message)
+	}
+	return spec, nil
+}
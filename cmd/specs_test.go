@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpecUnits(t *testing.T) {
+	tests := []struct {
+		spec     Spec
+		metric   string
+		imperial string
+	}{
+		{Spec{"Body mass", "9.3", "g"}, "9.3 g", "0.33 oz"},
+		{Spec{"Dimensions", "32 x 24 x 13", "mm"}, "32 × 24 × 13 mm", "1.26 × 0.94 × 0.51 in"},
+		{Spec{"Height", "2", "ft"}, "0.61 m", "2 ft"},
+		{Spec{"Feature", "Yellowish brown", ""}, "Yellowish brown", "Yellowish brown"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.spec.Metric(); got != tt.metric {
+			t.Errorf("%s: expected metric %q, got %q", tt.spec.Label, tt.metric, got)
+		}
+		if got := tt.spec.Imperial(); got != tt.imperial {
+			t.Errorf("%s: expected imperial %q, got %q", tt.spec.Label, tt.imperial, got)
+		}
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Spec
+		valid    bool
+	}{
+		{"Body mass=9.3g", Spec{"Body mass", "9.3", "g"}, true},
+		{"Dimensions = 32 x 24 x 13 mm", Spec{"Dimensions", "32 x 24 x 13", "mm"}, true},
+		{"Volume=2 fl oz", Spec{"Volume", "2", "fl oz"}, true},
+		{"Found in=Berlin", Spec{"Found in", "Berlin", ""}, true},
+		{"No value=", Spec{"No value", "", ""}, false},
+		{"Body mass", Spec{}, false},
+	}
+
+	for _, tt := range tests {
+		spec, err := parseSpec(tt.input)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseSpec(%q): expected valid=%v, got error %v", tt.input, tt.valid, err)
+		}
+		if !reflect.DeepEqual(spec, tt.expected) {
+			t.Errorf("parseSpec(%q) = %+v, expected %+v", tt.input, spec, tt.expected)
+		}
+	}
+}
+
+func TestValidateSpecs(t *testing.T) {
+	useTestUIFiles(t)
+
+	configData := `Pages:
+  page1:
+    ModelSrcPath: "/static/models/obj1/object1.glb"
+    ModelIosSrcPath: "/static/models/obj1/object1.usdz"
+    PosterPath: "/static/models/obj1/object1.webp"
+    ModelName: "Object 1"
+    Specs:
+      - Label: "Body mass"
+        Value: "heavy"
+        Unit: "g"
+      - Label: "Size"
+        Value: "3"
+        Unit: "furlong"
+        Colour: "red"
+`
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(filename, []byte(configData), 0644)
+
+	var messages []string
+	for _, p := range validateConfigFile(filename) {
+		messages = append(messages, p.String())
+	}
+	expected := []string{
+		filename + ":14:9: page page1: Specs[1]: unknown field Colour",
+		filename + `:9:16: page page1: Specs[0].Value "heavy" must be a number, or numbers separated by x for dimensions`,
+		filename + `:13:15: page page1: Specs[1].Unit "furlong" is not one of ` + strings.Join(unitNames(), ", "),
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Fatalf("Expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+	}
+}
+
+func TestRenderSpecs(t *testing.T) {
+	page := testPage("Model 1")
+	page.Specs = []Spec{{"Body mass", "9.3", "g"}, {"Feature", "Reddish dust", ""}}
+	config := Config{Pages: map[string]PageConfig{"page1": page, "page2": testPage("Model 2")}}
+
+	for _, layout := range []string{"card", "plain"} {
+		var buf strings.Builder
+		if err := renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page1", layout); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		html := buf.String()
+		if !strings.Contains(html, `data-metric="9.3 g" data-imperial="0.33 oz"`) || !strings.Contains(html, "<dd>Reddish dust</dd>") {
+			t.Errorf("Expected %s layout to render the specs, got %s", layout, html)
+		}
+		if strings.Contains(html, "contenteditable") {
+			t.Errorf("Expected %s layout to have no editable info bubble", layout)
+		}
+
+		buf.Reset()
+		renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page2", layout)
+		if strings.Contains(buf.String(), "message-bubble") {
+			t.Errorf("Expected %s layout to hide the info bubble of a page without specs", layout)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NodeID identifies a story node. graph.json may use numbers or strings as ids, so integer
+// ids are written back as numbers and every other id as a string.
+type NodeID string
+
+// StoryNode is a chapter of the story, with optional media shown when zooming in on it
+type StoryNode struct {
+	ID      NodeID   `json:"id"`
+	Keyword string   `json:"keyword"`
+	Story   string   `json:"story"`
+	Images  []string `json:"images,omitempty"`
+	Videos  []string `json:"videos,omitempty"`
+	Audios  []string `json:"audios,omitempty"`
+	Page    string   `json:"page,omitempty"` // key or slug of the model page the node is about
+
+	Translations map[string]StoryTranslation `json:"translations,omitempty"` // text of the node by locale, e.g. zh
+}
+
+// StoryLink connects two story nodes
+type StoryLink struct {
+	Source NodeID `json:"source"`
+	Target NodeID `json:"target"`
+}
+
+// StoryGraph is the content of graph.json, rendered by the story page
+type StoryGraph struct {
+	Nodes []StoryNode `json:"nodes"`
+	Links []StoryLink `json:"links"`
+}
+
+// MarshalJSON writes integer ids as JSON numbers and any other id as a string, including
+// ids such as 007 that are not written as JSON writes numbers
+func (id NodeID) MarshalJSON() ([]byte, error) {
+	if n, err := strconv.Atoi(string(id)); err == nil && strconv.Itoa(n) == string(id) {
+		return []byte(id), nil
+	}
+	return json.Marshal(string(id))
+}
+
+// UnmarshalJSON accepts an id written either as a JSON number or as a string
+func (id *NodeID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = NodeID(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("node id must be a string or a number, got %s", data)
+	}
+	*id = NodeID(n.String())
+	return nil
+}
+
+// readStoryGraph reads and decodes a graph.json file
+func readStoryGraph(filename string) (StoryGraph, error) {
+	var graph StoryGraph
+	data, err := readConfigFile(filename)
+	if err != nil {
+		return graph, err
+	}
+	err = json.Unmarshal(data, &graph)
+	return graph, err
+}
+
+// writeStoryGraph encodes graph and writes it to filename
+func writeStoryGraph(filename string, graph StoryGraph) error {
+	if graph.Nodes == nil {
+		graph.Nodes = []StoryNode{}
+	}
+	if graph.Links == nil {
+		graph.Links = []StoryLink{}
+	}
+
+	data, err := json.MarshalIndent(graph, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling story graph: %w", err)
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// node returns the index of the node with the given id, or -1 if there is none
+func (g StoryGraph) node(id NodeID) int {
+	for i, node := range g.Nodes {
+		if node.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// link returns the index of the link between source and target, or -1 if there is none
+func (g StoryGraph) link(source NodeID, target NodeID) int {
+	for i, link := range g.Links {
+		if link.Source == source && link.Target == target {
+			return i
+		}
+	}
+	return -1
+}
+
+// linksOf returns the links starting or ending at the node with the given id
+func (g StoryGraph) linksOf(id NodeID) []StoryLink {
+	var links []StoryLink
+	for _, link := range g.Links {
+		if link.Source == id || link.Target == id {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// validateStoryNode checks a single node, returning every invalid field
+func validateStoryNode(node StoryNode) []fieldError {
+	var errs []fieldError
+	if node.ID == "" {
+		errs = append(errs, fieldError{Field: "id", Message: "is required"})
+	} else if strings.ContainsAny(string(node.ID), "/?#") {
+		errs = append(errs, fieldError{Field: "id", Message: "may not contain '/', '?' or '#'"})
+	}
+	if strings.TrimSpace(node.Keyword) == "" {
+		errs = append(errs, fieldError{Field: "keyword", Message: "is required"})
+	}
+
+	media := map[string][]string{"images": node.Images, "videos": node.Videos, "audios": node.Audios}
+	for _, field := range []string{"images", "videos", "audios"} {
+		for i, src := range media[field] {
+			if strings.TrimSpace(src) == "" {
+				errs = append(errs, fieldError{Field: fmt.Sprintf("%s[%d]", field, i), Message: "must be a URL"})
+			}
+		}
+	}
+	return append(errs, validateTranslations("translations", node.Translations)...)
+}
+
+// validateStoryPage checks that the page a node refers to exists in pages
+func validateStoryPage(pages map[string]PageConfig, node StoryNode) []fieldError {
+	if node.Page == "" {
+		return nil
+	}
+	if _, ok := resolvePageRef(pages, node.Page); !ok {
+		return []fieldError{{Field: "page", Message: fmt.Sprintf("references unknown page %s", node.Page)}}
+	}
+	return nil
+}
+
+// resolvePageRef returns the key of the page a story node refers to by key or by slug
+func resolvePageRef(pages map[string]PageConfig, ref string) (string, bool) {
+	if _, ok := pages[ref]; ok {
+		return ref, true
+	}
+	for key, page := range pages {
+		if pageSlug(key, page) == ref {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// storyAppearance is a story node about a model page, shown on that page with its neighbours
+type storyAppearance struct {
+	Keyword    string
+	Story      string
+	Neighbours []storyNeighbour
+}
+
+// storyNeighbour is a node linked to a story appearance, along with the page it is about if any
+type storyNeighbour struct {
+	Keyword  string
+	PageSlug string
+}
+
+// pageStory returns the story nodes referring to the page stored under key
+func pageStory(config Config, graph StoryGraph, key string) []storyAppearance {
+	var appearances []storyAppearance
+	for _, node := range graph.Nodes {
+		if ref, ok := resolvePageRef(config.Pages, node.Page); !ok || ref != key {
+			continue
+		}
+
+		appearance := storyAppearance{Keyword: node.Keyword, Story: node.Story}
+		for _, link := range graph.linksOf(node.ID) {
+			other := link.Target
+			if other == node.ID {
+				other = link.Source
+			}
+			i := graph.node(other)
+			if i < 0 {
+				continue
+			}
+
+			neighbour := storyNeighbour{Keyword: graph.Nodes[i].Keyword}
+			if ref, ok := resolvePageRef(config.Pages, graph.Nodes[i].Page); ok {
+				neighbour.PageSlug = pageSlug(ref, config.Pages[ref])
+			}
+			appearance.Neighbours = append(appearance.Neighbours, neighbour)
+		}
+		appearances = append(appearances, appearance)
+	}
+	return appearances
+}
+
+// validateStoryLink checks that a link connects two existing nodes of graph
+func validateStoryLink(graph StoryGraph, link StoryLink) []fieldError {
+	var errs []fieldError
+	ends := []struct {
+		field string
+		id    NodeID
+	}{{"source", link.Source}, {"target", link.Target}}
+
+	for _, end := range ends {
+		if end.id == "" {
+			errs = append(errs, fieldError{Field: end.field, Message: "is required"})
+		} else if graph.node(end.id) < 0 {
+			errs = append(errs, fieldError{Field: end.field, Message: fmt.Sprintf("references unknown node %s", end.id)})
+		}
+	}
+	return errs
+}
+
+// defaultStoryDepth is how many links away from a matching node a filtered graph reaches
+const defaultStoryDepth = 1
+
+// filterStoryGraph returns the subgraph of nodes matching query along with the nodes up to depth
+// links away from them. Every word of query must be the prefix of a word of the node's keyword
+// or story, ignoring case; an empty query matches the whole graph.
+func filterStoryGraph(graph StoryGraph, query string, depth int) StoryGraph {
+	terms := storyWords(query)
+	if len(terms) == 0 {
+		return graph
+	}
+
+	included := make(map[NodeID]bool)
+	var frontier []NodeID
+	for _, node := range graph.Nodes {
+		if matchesStoryNode(node, terms) {
+			included[node.ID] = true
+			frontier = append(frontier, node.ID)
+		}
+	}
+
+	// Walk the links in both directions, one level per step
+	for ; depth > 0 && len(frontier) > 0; depth-- {
+		var next []NodeID
+		for _, id := range frontier {
+			for _, link := range graph.linksOf(id) {
+				for _, end := range []NodeID{link.Source, link.Target} {
+					if !included[end] {
+						included[end] = true
+						next = append(next, end)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	filtered := StoryGraph{Nodes: []StoryNode{}, Links: []StoryLink{}}
+	for _, node := range graph.Nodes {
+		if included[node.ID] {
+			filtered.Nodes = append(filtered.Nodes, node)
+		}
+	}
+	for _, link := range graph.Links {
+		if included[link.Source] && included[link.Target] {
+			filtered.Links = append(filtered.Links, link)
+		}
+	}
+	return filtered
+}
+
+// matchesStoryNode reports whether every term is the prefix of a word of the node's text
+func matchesStoryNode(node StoryNode, terms []string) bool {
+	words := append(storyWords(node.Keyword), storyWords(node.Story)...)
+	for _, term := range terms {
+		found := false
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// storyWords splits text into lowercase words of letters and digits
+func storyWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseStoryDepth reads the depth query parameter, defaulting to defaultStoryDepth
+func parseStoryDepth(value string) (int, error) {
+	if value == "" {
+		return defaultStoryDepth, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 0 {
+		return 0, fmt.Errorf("depth must be a non-negative integer, got %q", value)
+	}
+	return depth, nil
+}
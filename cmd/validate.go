@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// problem is a single schema violation found in a configuration file
+type problem struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+	Missing bool // a file not found under ui/static, which the running server can do without
+}
+
+// String formats a problem as file:line:column: message, as compilers and linters do
+func (p problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// splitMissing separates the problems about files not found under ui/static from the others
+func splitMissing(problems []problem) (errs []problem, missing []problem) {
+	for _, p := range problems {
+		if p.Missing {
+			missing = append(missing, p)
+		} else {
+			errs = append(errs, p)
+		}
+	}
+	return errs, missing
+}
+
+// problemsError joins problems into a single error, one problem per line
+func problemsError(problems []problem) error {
+	messages := make([]string, len(problems))
+	for i, p := range problems {
+		messages[i] = p.String()
+	}
+	return errors.New(strings.Join(messages, "\n"))
+}
+
+// fieldError describes an invalid field of a page or story element
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Missing bool   `json:"-"` // the field names a file not found under ui/static
+}
+
+// requiredPageFields lists the PageConfig fields that must not be empty
+var requiredPageFields = []string{"ModelSrcPath", "ModelIosSrcPath", "PosterPath", "ModelName"}
+
+// pageKeyPattern splits a page key into its prefix and number, e.g. page12
+var pageKeyPattern = regexp.MustCompile(`^(\D*)(\d+)$`)
+
+// validatePage checks a single page against the schema, returning every invalid field
+func validatePage(page PageConfig) []fieldError {
+	var errs []fieldError
+	value := reflect.ValueOf(page)
+
+	for _, field := range requiredPageFields {
+		if strings.TrimSpace(value.FieldByName(field).String()) == "" {
+			errs = append(errs, fieldError{Field: field, Message: "is required"})
+		}
+	}
+
+	// Model files must be served from the static directory
+	for _, field := range []string{"ModelSrcPath", "ModelIosSrcPath", "PosterPath"} {
+		if msg, missing := checkStaticPath(value.FieldByName(field).String()); msg != "" {
+			errs = append(errs, fieldError{Field: field, Message: msg, Missing: missing})
+		}
+	}
+
+	if page.DesignerWebsite != "" {
+		u, err := url.Parse(page.DesignerWebsite)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fieldError{Field: "DesignerWebsite", Message: fmt.Sprintf("%q is not a valid http(s) URL", page.DesignerWebsite)})
+		}
+	}
+
+	if page.Slug != "" && !slugPattern.MatchString(page.Slug) {
+		errs = append(errs, fieldError{Field: "Slug", Message: fmt.Sprintf("%q may only contain letters, digits, '-' and '_'", page.Slug)})
+	}
+	if page.Order < 0 {
+		errs = append(errs, fieldError{Field: "Order", Message: "must not be negative"})
+	}
+	if msg := checkLayout(page.Layout); msg != "" {
+		errs = append(errs, fieldError{Field: "Layout", Message: msg})
+	}
+	for i, spec := range page.Specs {
+		for _, fe := range validateSpec(spec) {
+			fe.Field = fmt.Sprintf("Specs[%d].%s", i, fe.Field)
+			errs = append(errs, fe)
+		}
+	}
+	errs = append(errs, validateMaterials(page.Materials)...)
+	errs = append(errs, validateCredits(page)...)
+	errs = append(errs, validateVariants(page)...)
+	errs = append(errs, validateTranslations("Translations", page.Translations)...)
+	for i, a := range page.Annotations {
+		for _, fe := range validateAnnotation(a) {
+			fe.Field = fmt.Sprintf("Annotations[%d].%s", i, fe.Field)
+			errs = append(errs, fe)
+		}
+	}
+
+	return errs
+}
+
+// checkStaticPath returns why a /static/ path does not resolve to a file, or "" if it does,
+// and whether the reason is only that the file does not exist
+func checkStaticPath(p string) (string, bool) {
+	if p == "" {
+		return "", false
+	}
+	if !strings.HasPrefix(p, "/static/") {
+		return fmt.Sprintf("%q must start with /static/", p), false
+	}
+
+	info, err := fs.Stat(uiFiles, strings.TrimPrefix(p, "/"))
+	if err != nil {
+		return fmt.Sprintf("file %s not found under ui/static", p), true
+	}
+	if info.IsDir() {
+		return fmt.Sprintf("%s is a directory, not a file", p), false
+	}
+	return "", false
+}
+
+// validateConfigFile checks a config.yaml file against the page schema
+func validateConfigFile(filename string) []problem {
+	data, err := readConfigFile(filename)
+	if err != nil {
+		return []problem{{File: filename, Message: err.Error()}}
+	}
+	return validateConfigData(filename, data)
+}
+
+// validateConfigData checks the content of a config.yaml file against the page schema,
+// reporting the problems under filename
+func validateConfigData(filename string, data []byte) []problem {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []problem{{File: filename, Message: err.Error()}}
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return []problem{{File: filename, Message: "expected a mapping with a Pages key"}}
+	}
+
+	report := func(node *yaml.Node, format string, args ...any) problem {
+		return problem{File: filename, Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)}
+	}
+
+	pagesNode := mappingValue(doc.Content[0], "Pages")
+	if pagesNode == nil {
+		return []problem{report(doc.Content[0], "missing required key Pages")}
+	}
+	if pagesNode.Kind != yaml.MappingNode {
+		return []problem{report(pagesNode, "Pages must be a mapping of page keys to pages")}
+	}
+
+	var problems []problem
+	if siteNode := mappingValue(doc.Content[0], "Site"); siteNode != nil {
+		problems = append(problems, validateSiteNode(siteNode, report)...)
+	}
+
+	knownFields := yamlFieldNames(reflect.TypeOf(PageConfig{}))
+	listFields := []struct {
+		name  string
+		known map[string]bool
+	}{
+		{"Specs", yamlFieldNames(reflect.TypeOf(Spec{}))},
+		{"Materials", yamlFieldNames(reflect.TypeOf(MaterialPreset{}))},
+		{"Annotations", yamlFieldNames(reflect.TypeOf(Annotation{}))},
+		{"Variants", yamlFieldNames(reflect.TypeOf(Variant{}))},
+		{"Contributors", yamlFieldNames(reflect.TypeOf(Contributor{}))},
+	}
+	translationFields := yamlFieldNames(reflect.TypeOf(PageTranslation{}))
+	seenKeys := make(map[string]*yaml.Node)
+	seenSlugs := make(map[string]string)
+	seenNumbers := make(map[int]string)
+	prefixes := make(map[string]string)
+
+	for i := 0; i+1 < len(pagesNode.Content); i += 2 {
+		keyNode, pageNode := pagesNode.Content[i], pagesNode.Content[i+1]
+		key := keyNode.Value
+
+		if first, ok := seenKeys[key]; ok {
+			problems = append(problems, report(keyNode, "duplicate page key %s (first defined on line %d)", key, first.Line))
+			continue
+		}
+		seenKeys[key] = keyNode
+
+		// Numbered keys must share a prefix and never reuse a number, since /modelN relies on them
+		if match := pageKeyPattern.FindStringSubmatch(key); match != nil {
+			number, _ := extractNumber(key)
+			if other, ok := seenNumbers[number]; ok {
+				problems = append(problems, report(keyNode, "page %s reuses number %d of page %s", key, number, other))
+			}
+			seenNumbers[number] = key
+			prefixes[match[1]] = key
+		}
+
+		if pageNode.Kind != yaml.MappingNode {
+			problems = append(problems, report(pageNode, "page %s must be a mapping of fields", key))
+			continue
+		}
+		for j := 0; j+1 < len(pageNode.Content); j += 2 {
+			if field := pageNode.Content[j]; !knownFields[field.Value] {
+				problems = append(problems, report(field, "page %s: unknown field %s", key, field.Value))
+			}
+		}
+		for _, list := range listFields {
+			items := mappingValue(pageNode, list.name)
+			if items == nil || items.Kind != yaml.SequenceNode {
+				continue
+			}
+			for j, item := range items.Content {
+				for k := 0; k+1 < len(item.Content); k += 2 {
+					if field := item.Content[k]; !list.known[field.Value] {
+						problems = append(problems, report(field, "page %s: %s[%d]: unknown field %s", key, list.name, j, field.Value))
+					}
+				}
+			}
+		}
+		if translations := mappingValue(pageNode, "Translations"); translations != nil {
+			for j := 0; j+1 < len(translations.Content); j += 2 {
+				locale, fields := translations.Content[j].Value, translations.Content[j+1]
+				for k := 0; k+1 < len(fields.Content); k += 2 {
+					if field := fields.Content[k]; !translationFields[field.Value] {
+						problems = append(problems, report(field, "page %s: Translations.%s: unknown field %s", key, locale, field.Value))
+					}
+				}
+			}
+		}
+
+		var page PageConfig
+		if err := pageNode.Decode(&page); err != nil {
+			problems = append(problems, report(pageNode, "page %s: %s", key, err))
+			continue
+		}
+
+		for _, fe := range validatePage(page) {
+			node := fieldNode(pageNode, fe.Field)
+			if node == nil {
+				node = keyNode
+			}
+			p := report(node, "page %s: %s %s", key, fe.Field, fe.Message)
+			p.Missing = fe.Missing
+			problems = append(problems, p)
+		}
+
+		slug := pageSlug(key, page)
+		if other, ok := seenSlugs[slug]; ok {
+			problems = append(problems, report(keyNode, "page %s: slug %q is already used by page %s", key, slug, other))
+		}
+		seenSlugs[slug] = key
+	}
+
+	if len(prefixes) > 1 {
+		var names []string
+		for prefix, key := range prefixes {
+			names = append(names, fmt.Sprintf("%q (%s)", prefix, key))
+		}
+		sort.Strings(names)
+		problems = append(problems, report(pagesNode, "numbered page keys use different prefixes: %s", strings.Join(names, ", ")))
+	}
+
+	return problems
+}
+
+// validateSiteNode checks the optional Site block of a config file
+func validateSiteNode(siteNode *yaml.Node, report func(*yaml.Node, string, ...any) problem) []problem {
+	if siteNode.Kind != yaml.MappingNode {
+		return []problem{report(siteNode, "Site must be a mapping of settings")}
+	}
+
+	var problems []problem
+	knownFields := yamlFieldNames(reflect.TypeOf(SiteConfig{}))
+	for i := 0; i+1 < len(siteNode.Content); i += 2 {
+		if field := siteNode.Content[i]; !knownFields[field.Value] {
+			problems = append(problems, report(field, "Site: unknown field %s", field.Value))
+		}
+	}
+
+	var site SiteConfig
+	if err := siteNode.Decode(&site); err != nil {
+		return append(problems, report(siteNode, "Site: %s", err))
+	}
+	for _, fe := range validateSite(site) {
+		node := mappingValue(siteNode, fe.Field)
+		if node == nil {
+			node = siteNode
+		}
+		problems = append(problems, report(node, "Site: %s %s", fe.Field, fe.Message))
+	}
+	return problems
+}
+
+// mappingValue returns the value node stored under key in a YAML mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// fieldNode returns the node of a page field, following list indexes such as Specs[0].Unit
+func fieldNode(pageNode *yaml.Node, field string) *yaml.Node {
+	node := pageNode
+	for _, part := range strings.Split(field, ".") {
+		name, index, hasIndex := strings.Cut(strings.TrimSuffix(part, "]"), "[")
+		node = mappingValue(node, name)
+		if node == nil {
+			return nil
+		}
+		if hasIndex {
+			i, err := strconv.Atoi(index)
+			if err != nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[i]
+		}
+	}
+	return node
+}
+
+// yamlFieldNames returns the set of YAML keys accepted by a struct type
+func yamlFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// validateGraphFile checks that a graph.json file holds nodes with unique ids and links between them.
+// Nodes referring to a model page are checked against pages unless it is nil.
+func validateGraphFile(filename string, pages map[string]PageConfig) []problem {
+	data, err := readConfigFile(filename)
+	if err != nil {
+		return []problem{{File: filename, Message: err.Error()}}
+	}
+
+	report := func(offset int64, format string, args ...any) problem {
+		line, column := lineColumn(data, offset)
+		return problem{File: filename, Line: line, Column: column, Message: fmt.Sprintf(format, args...)}
+	}
+
+	sections, err := decodeGraphSections(data)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return []problem{report(syntaxErr.Offset, "%s", err)}
+		}
+		return []problem{{File: filename, Message: err.Error()}}
+	}
+
+	var problems []problem
+	var graph StoryGraph
+
+	for i, element := range sections["nodes"] {
+		var node StoryNode
+		if err := json.Unmarshal(element.raw, &node); err != nil {
+			problems = append(problems, report(element.offset, "nodes[%d]: %s", i, err))
+			continue
+		}
+
+		name := fmt.Sprintf("nodes[%d]", i)
+		if node.ID != "" {
+			name = fmt.Sprintf("node %s", node.ID)
+			if graph.node(node.ID) >= 0 {
+				problems = append(problems, report(element.offset, "nodes[%d]: duplicate node id %s", i, node.ID))
+			}
+		}
+		errs := validateStoryNode(node)
+		if pages != nil {
+			errs = append(errs, validateStoryPage(pages, node)...)
+		}
+		for _, fe := range errs {
+			problems = append(problems, report(element.offset, "%s: %s %s", name, fe.Field, fe.Message))
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	for i, element := range sections["links"] {
+		var link StoryLink
+		if err := json.Unmarshal(element.raw, &link); err != nil {
+			problems = append(problems, report(element.offset, "links[%d]: %s", i, err))
+			continue
+		}
+		for _, fe := range validateStoryLink(graph, link) {
+			problems = append(problems, report(element.offset, "links[%d]: %s %s", i, fe.Field, fe.Message))
+		}
+	}
+
+	return problems
+}
+
+// graphElement is one raw element of a graph.json array along with its byte offset
+type graphElement struct {
+	raw    json.RawMessage
+	offset int64
+}
+
+// decodeGraphSections splits the top-level arrays of a graph.json document into their elements
+func decodeGraphSections(data []byte) (map[string][]graphElement, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object with nodes and links")
+	}
+
+	sections := make(map[string][]graphElement)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		if key != "nodes" && key != "links" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if tok, err := dec.Token(); err != nil {
+			return nil, err
+		} else if tok != json.Delim('[') {
+			return nil, fmt.Errorf("%s must be an array", key)
+		}
+		sections[key] = []graphElement{}
+		for dec.More() {
+			// Skip the separator so the offset points at the element itself
+			offset := dec.InputOffset()
+			for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[offset])) {
+				offset++
+			}
+
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			sections[key] = append(sections[key], graphElement{raw, offset})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range []string{"nodes", "links"} {
+		if _, ok := sections[key]; !ok {
+			return nil, fmt.Errorf("missing required key %s", key)
+		}
+	}
+	return sections, nil
+}
+
+// lineColumn converts a byte offset into 1-based line and column numbers
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
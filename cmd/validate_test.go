@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// useTestUIFiles overlays fake model files on the UI files for the duration of a test
+func useTestUIFiles(t *testing.T) {
+	original := uiFiles
+	uiFiles = overlayFS{
+		upper: fstest.MapFS{
+			"static/models/obj1/object1.glb":  {Data: []byte("glb")},
+			"static/models/obj1/object1.usdz": {Data: []byte("usdz")},
+			"static/models/obj1/object1.webp": {Data: []byte("webp")},
+		},
+		lower: original,
+	}
+	t.Cleanup(func() { uiFiles = original })
+}
+
+func TestValidatePage(t *testing.T) {
+	useTestUIFiles(t)
+
+	valid := PageConfig{
+		ModelSrcPath:    "/static/models/obj1/object1.glb",
+		ModelIosSrcPath: "/static/models/obj1/object1.usdz",
+		PosterPath:      "/static/models/obj1/object1.webp",
+		ModelName:       "Object 1",
+		DesignerWebsite: "https://example.com/designer1",
+	}
+	if errs := validatePage(valid); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	invalid := PageConfig{
+		ModelSrcPath:    "/static/models/obj2/object2.glb",
+		ModelIosSrcPath: "models/obj1/object1.usdz",
+		DesignerWebsite: "example.com",
+		Slug:            "not a slug",
+	}
+	fields := make(map[string]bool)
+	for _, fe := range validatePage(invalid) {
+		fields[fe.Field] = true
+	}
+	for _, field := range []string{"ModelSrcPath", "ModelIosSrcPath", "PosterPath", "ModelName", "DesignerWebsite", "Slug"} {
+		if !fields[field] {
+			t.Errorf("Expected an error for field %s", field)
+		}
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	useTestUIFiles(t)
+
+	configData := `Pages:
+  page1:
+    ModelSrcPath: "/static/models/obj1/object1.glb"
+    ModelIosSrcPath: "/static/models/obj1/object1.usdz"
+    PosterPath: "/static/models/obj1/object1.webp"
+    ModelName: "Object 1"
+  page01:
+    ModelSrcPath: "/static/models/obj1/missing.glb"
+    ModelIosSrcPath: "/static/models/obj1/object1.usdz"
+    PosterPath: "/static/models/obj1/object1.webp"
+    ModelNme: "Typo"
+`
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(filename, []byte(configData), 0644)
+
+	problems := validateConfigFile(filename)
+	expected := []string{
+		":7:3: page page01 reuses number 1 of page page1",
+		":11:5: page page01: unknown field ModelNme",
+		":7:3: page page01: ModelName is required",
+		":8:19: page page01: ModelSrcPath file /static/models/obj1/missing.glb not found under ui/static",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got %v", len(expected), problems)
+	}
+	for i, p := range problems {
+		if !strings.HasSuffix(p.String(), expected[i]) {
+			t.Errorf("Expected problem %q, got %q", expected[i], p.String())
+		}
+	}
+	if errs, missing := splitMissing(problems); len(errs) != 3 || len(missing) != 1 {
+		t.Errorf("Expected the missing file to be told apart from the other problems, got %v and %v", errs, missing)
+	}
+}
+
+func TestValidateGraphFile(t *testing.T) {
+	graphData := `{
+  "nodes": [
+    { "id": 1, "keyword": "Introduction" },
+    { "id": "node2", "keyword": "" }
+  ],
+  "links": [
+    { "source": 1, "target": "node2" },
+    { "source": 1, "target": 3 }
+  ]
+}`
+	filename := filepath.Join(t.TempDir(), "graph.json")
+	os.WriteFile(filename, []byte(graphData), 0644)
+
+	problems := validateGraphFile(filename, nil)
+	expected := []string{
+		":4:5: node node2: keyword is required",
+		":8:5: links[1]: target references unknown node 3",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got %v", len(expected), problems)
+	}
+	for i, p := range problems {
+		if !strings.HasSuffix(p.String(), expected[i]) {
+			t.Errorf("Expected problem %q, got %q", expected[i], p.String())
+		}
+	}
+}
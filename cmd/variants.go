@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Variant is another state of a page's model, such as a restored version or a cross-section,
+// selectable with the variant picker and linked to with ?variant=<Slug>
+type Variant struct {
+	Name            string `yaml:"Name"`
+	Slug            string `yaml:"Slug,omitempty"` // defaults to the name in lowercase with dashes
+	ModelSrcPath    string `yaml:"ModelSrcPath"`
+	ModelIosSrcPath string `yaml:"ModelIosSrcPath"`
+	PosterPath      string `yaml:"PosterPath"`
+}
+
+// defaultVariantName labels the page's own model in the variant picker unless VariantName is set
+const defaultVariantName = "Original"
+
+// variantSlugSeparators matches the runs of characters replaced by a dash in derived slugs
+var variantSlugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// VariantSlug returns the slug identifying the variant in ?variant= links
+func (v Variant) VariantSlug() string {
+	if v.Slug != "" {
+		return v.Slug
+	}
+	return strings.Trim(variantSlugSeparators.ReplaceAllString(strings.ToLower(v.Name), "-"), "-")
+}
+
+// AllVariants returns the variants of the page, starting with its own model, or nil if it has
+// no other variant
+func (p PageConfig) AllVariants() []Variant {
+	if len(p.Variants) == 0 {
+		return nil
+	}
+
+	name := p.VariantName
+	if name == "" {
+		name = defaultVariantName
+	}
+	own := Variant{Name: name, ModelSrcPath: p.ModelSrcPath, ModelIosSrcPath: p.ModelIosSrcPath, PosterPath: p.PosterPath}
+	return append([]Variant{own}, p.Variants...)
+}
+
+// validateVariants checks the variants of a page, returning every invalid field
+func validateVariants(page PageConfig) []fieldError {
+	var errs []fieldError
+	slugs := make(map[string]string)
+	for i, v := range page.AllVariants() {
+		field := func(name string) string { return fmt.Sprintf("Variants[%d].%s", i-1, name) }
+		if i == 0 {
+			slugs[v.VariantSlug()] = "the page's own model"
+			continue
+		}
+
+		if strings.TrimSpace(v.Name) == "" {
+			errs = append(errs, fieldError{Field: field("Name"), Message: "is required"})
+			continue
+		}
+		slug := v.VariantSlug()
+		if !slugPattern.MatchString(slug) {
+			errs = append(errs, fieldError{Field: field("Slug"), Message: fmt.Sprintf("%q may only contain letters, digits, '-' and '_'", slug)})
+		} else if other, ok := slugs[slug]; ok {
+			errs = append(errs, fieldError{Field: field("Slug"), Message: fmt.Sprintf("%q is already used by %s", slug, other)})
+		} else {
+			slugs[slug] = fmt.Sprintf("variant %s", v.Name)
+		}
+
+		value := reflect.ValueOf(v)
+		for _, name := range []string{"ModelSrcPath", "ModelIosSrcPath", "PosterPath"} {
+			p := value.FieldByName(name).String()
+			if strings.TrimSpace(p) == "" {
+				errs = append(errs, fieldError{Field: field(name), Message: "is required"})
+			} else if msg, missing := checkStaticPath(p); msg != "" {
+				errs = append(errs, fieldError{Field: field(name), Message: msg, Missing: missing})
+			}
+		}
+	}
+	return errs
+}
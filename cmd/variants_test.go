@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testVariant returns a variant using the fake model files of useTestUIFiles
+func testVariant(name string) Variant {
+	return Variant{
+		Name:            name,
+		ModelSrcPath:    "/static/models/obj1/object1.glb",
+		ModelIosSrcPath: "/static/models/obj1/object1.usdz",
+		PosterPath:      "/static/models/obj1/object1.webp",
+	}
+}
+
+func TestVariantSlug(t *testing.T) {
+	tests := map[Variant]string{
+		{Name: "Restored"}:                   "restored",
+		{Name: "Cross-section (2024)"}:       "cross-section-2024",
+		{Name: "Raw scan", Slug: "raw_scan"}: "raw_scan",
+	}
+	for variant, expected := range tests {
+		if slug := variant.VariantSlug(); slug != expected {
+			t.Errorf("VariantSlug(%q) = %q, expected %q", variant.Name, slug, expected)
+		}
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	useTestUIFiles(t)
+
+	page := testPage("Model 1")
+	page.Variants = []Variant{testVariant("Restored"), testVariant("Cross section")}
+	if errs := validatePage(page); len(errs) != 0 {
+		t.Fatalf("Expected no errors, got %v", errs)
+	}
+
+	page.Variants = []Variant{
+		{Name: "Original"},
+		testVariant("Bad slug!"),
+		{Name: ""},
+	}
+	page.Variants[1].Slug = "bad slug"
+	var fields []string
+	for _, fe := range validateVariants(page) {
+		fields = append(fields, fe.Field)
+	}
+	expected := []string{
+		"Variants[0].Slug", "Variants[0].ModelSrcPath", "Variants[0].ModelIosSrcPath", "Variants[0].PosterPath",
+		"Variants[1].Slug", "Variants[2].Name",
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Expected errors for %v, got %v", expected, fields)
+	}
+}
+
+func TestRenderVariants(t *testing.T) {
+	page := testPage("Model 1")
+	page.VariantName = "Raw scan"
+	restored := testVariant("Restored")
+	restored.ModelSrcPath = "/static/models/obj1/restored.glb"
+	page.Variants = []Variant{restored}
+	config := Config{Pages: map[string]PageConfig{"page1": page, "page2": testPage("Model 2")}}
+
+	for _, layout := range []string{"card", "plain"} {
+		var buf strings.Builder
+		if err := renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page1", layout); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		html := buf.String()
+		if !strings.Contains(html, `class="variant selected" data-variant=""`) || !strings.Contains(html, ">Raw scan</button>") {
+			t.Errorf("Expected %s layout to select the page's own model first, got %s", layout, html)
+		}
+		if !strings.Contains(html, `data-variant="restored"`) || !strings.Contains(html, `data-src="/static/models/obj1/restored.glb"`) {
+			t.Errorf("Expected %s layout to list the restored variant", layout)
+		}
+
+		buf.Reset()
+		renderPage(&buf, parseTemplates(), config, StoryGraph{}, "page2", layout)
+		if strings.Contains(buf.String(), `id="variant-picker"`) {
+			t.Errorf("Expected %s layout to have no variant picker for a single model", layout)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package configs
+
+import "embed"
+
+// Files holds the sample configuration used when no configuration exists on disk
+//
+//go:embed "config.yaml" "graph.json"
+var Files embed.FS
@@ -0,0 +1,8 @@
+package ui
+
+import "embed"
+
+// Files holds the default templates and static assets compiled into the binary
+//
+//go:embed "html/*.html" "html/templates" "html/layouts" "i18n" "static"
+var Files embed.FS
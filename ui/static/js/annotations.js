@@ -0,0 +1,28 @@
+// Open the popup of an annotation hotspot, closing any other one
+function toggleAnnotation(hotspot) {
+  const open = hotspot.classList.contains('open');
+  document.querySelectorAll('.annotation.open').forEach(a => a.classList.remove('open'));
+  if (!open) {
+    hotspot.classList.add('open');
+  }
+}
+
+document.addEventListener("DOMContentLoaded", function() {
+  const checkbox = document.getElementById('show-annotations');
+  if (!checkbox) {
+    return;
+  }
+
+  const annotations = document.querySelectorAll('.annotation');
+  checkbox.addEventListener('change', () => {
+    annotations.forEach(a => {
+      a.classList.toggle('hide', !checkbox.checked);
+      a.classList.remove('open');
+    });
+  });
+
+  // Clicks inside a popup, e.g. on its media controls, should not close it
+  document.querySelectorAll('.annotation-popup').forEach(popup => {
+    popup.addEventListener('click', event => event.stopPropagation());
+  });
+});
@@ -1,11 +1,75 @@
+// Texture the model was loaded with, restored by presets without a texture of their own
+let originalTexture;
+
+// A new model is loaded when switching variants, along with its own texture
+document.addEventListener("DOMContentLoaded", () => {
+  document.querySelector("model-viewer#transformer").addEventListener("load", () => {
+    originalTexture = undefined;
+  });
+});
+
+// Apply the material preset of a swatch: base color, and when the preset sets them,
+// metalness, roughness, emissive color and texture
+async function applyMaterial(modelViewer, swatch) {
+  const [material] = modelViewer.model.materials;
+  const pbr = material.pbrMetallicRoughness;
+  const preset = swatch.dataset;
+
+  pbr.setBaseColorFactor(preset.color);
+
+  // Keep the toolbox sliders in sync with the preset
+  [['metalness', 'setMetallicFactor'], ['roughness', 'setRoughnessFactor']].forEach(([name, setter]) => {
+    if (preset[name] === undefined) {
+      return;
+    }
+    const value = parseFloat(preset[name]);
+    pbr[setter](value);
+
+    const slider = document.getElementById(name);
+    const display = document.getElementById(name + '-value');
+    if (slider) slider.value = value;
+    if (display) display.textContent = value;
+  });
+
+  if (preset.emissive !== undefined) {
+    material.setEmissiveFactor(preset.emissive);
+  }
+  if (originalTexture === undefined) {
+    originalTexture = pbr.baseColorTexture.texture;
+  }
+  if (preset.texture !== undefined) {
+    const texture = await modelViewer.createTexture(preset.texture);
+    pbr.baseColorTexture.setTexture(texture);
+  } else if (pbr.baseColorTexture.texture !== originalTexture) {
+    pbr.baseColorTexture.setTexture(originalTexture);
+  }
+
+  document.querySelectorAll('#color-controls .swatch').forEach(s => s.classList.toggle('selected', s === swatch));
+}
+
 function colorControl() {
   const modelViewerColor = document.querySelector("model-viewer#transformer");
+  const controls = document.querySelector('#color-controls');
 
-  document.querySelector('#color-controls').addEventListener('click', (event) => {
-    const colorString = event.target.dataset.color;
-    const [material] = modelViewerColor.model.materials;
-    material.pbrMetallicRoughness.setBaseColorFactor(colorString);
+  controls.addEventListener('click', (event) => {
+    const swatch = event.target.closest('.swatch');
+    if (swatch) {
+      applyMaterial(modelViewerColor, swatch);
+    }
   });
+
+  // Start from the default preset of the page, if it declares one
+  const applyDefault = () => {
+    const preset = controls.querySelector('.swatch[data-default]');
+    if (preset) {
+      applyMaterial(modelViewerColor, preset);
+    }
+  };
+  if (modelViewerColor.loaded) {
+    applyDefault();
+  } else {
+    modelViewerColor.addEventListener('load', applyDefault);
+  }
 }
 
 // Call the function to ensure the controls are initialized when the script is loaded
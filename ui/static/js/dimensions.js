@@ -2,7 +2,7 @@ const modelViewer = document.querySelector('#transformer');
 
 const checkbox = modelViewer.querySelector('#show-dimensions');
 
-const dimElements = [...modelViewer.querySelectorAll('button'), modelViewer.querySelector('#dimLines')];
+const dimElements = [...modelViewer.querySelectorAll('button.dot, button.dim'), modelViewer.querySelector('#dimLines')];
 
 function setVisibility(visible) {
   dimElements.forEach((element) => {
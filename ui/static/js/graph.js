@@ -4,7 +4,30 @@ const height = document.getElementById('graph-container').offsetHeight;
 let zoom; // Declare zoom globally to access it in the reset function
 let brushing = false; // Track if brushing mode is enabled
 
-function createGraph(data) {
+// Find the model page a story node refers to by key or by slug
+function findPage(pages, ref) {
+  return pages.find(page => page.key === ref || page.url === 'models/' + ref);
+}
+
+// Render a thumbnail and link to the model page a story node is about
+function modelLink(page) {
+  const link = document.createElement('a');
+  link.className = 'story-model';
+  link.href = page.url;
+
+  const poster = document.createElement('img');
+  poster.src = page.PosterPath.replace(/^\//, '');
+  poster.alt = page.ModelName;
+  poster.loading = 'lazy';
+  link.appendChild(poster);
+
+  const label = document.createElement('span');
+  label.textContent = document.getElementById('graph-container').dataset.viewModelLabel + page.ModelName;
+  link.appendChild(label);
+  return link;
+}
+
+function createGraph(data, pages) {
   const svg = d3.select('#graph-container').append('svg')
     .attr('width', width)
     .attr('height', height);
@@ -48,7 +71,13 @@ function createGraph(data) {
     // Highlight the in-degree edges
     link.attr('stroke', link => link.target.id === d.id ? '#ff5722' : '#999');
 
-    document.getElementById('story-content').innerHTML = d.story;
+    const content = document.getElementById('story-content');
+    content.textContent = d.story;
+
+    const page = d.page && findPage(pages, d.page);
+    if (page) {
+      content.appendChild(modelLink(page));
+    }
   };
 
   function updateVisibility(zoomLevel) {
@@ -237,8 +266,15 @@ function createGraph(data) {
       });
 
       if (selectedNodes.size() > 0) {
-        const stories = selectedNodes.data().map(d => d.story).join("<br><br>");
-        document.getElementById('story-content').innerHTML = stories;
+        // Story text is shown as text, never parsed as markup
+        const content = document.getElementById('story-content');
+        content.replaceChildren();
+        selectedNodes.data().forEach((d, i) => {
+          if (i > 0) {
+            content.append(document.createElement("br"), document.createElement("br"));
+          }
+          content.append(d.story);
+        });
       }
     }
   }
@@ -308,11 +344,21 @@ function zoomOut() {
 }
 
 document.addEventListener("DOMContentLoaded", function() {
-  // Fetch the graph data from the JSON file
-  fetch('./graph.json')
+  // A filtered graph is embedded in the page, otherwise fetch the whole graph from the JSON file
+  const embedded = document.getElementById('graph-data');
+  const graphData = embedded
+    ? Promise.resolve(JSON.parse(embedded.textContent))
+    : fetch('./graph.json').then(response => response.json());
+
+  // Model pages are looked up for the nodes that refer to one, the graph works without them
+  const pagesData = fetch('./api/pages')
     .then(response => response.json())
-    .then(data => {
-      createGraph(data);
+    .then(pages => pages.map(page => ({ ...page, url: page.url.replace(/^\//, '') })))
+    .catch(() => []);
+
+  Promise.all([graphData, pagesData])
+    .then(([data, pages]) => {
+      createGraph(data, pages);
     })
     .catch(error => console.error('Error loading the JSON file:', error));
 
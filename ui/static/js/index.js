@@ -4,7 +4,7 @@ import { LightningStrike } from './jsm/geometries/LightningStrike.js';
 
 let container, stats;
 let camera, scene, renderer;
-let pageCount;
+let pages;
 let particles, octahedron;
 let raycaster, mouse;
 let lightningStrike, lightningStrikeMesh;
@@ -31,20 +31,22 @@ window.addEventListener('pageshow', function(event) {
 
 init();
 
-async function getYamlPagesCount() {
+async function getPages() {
   try {
-    const response = await fetch('./config.yaml');
+    const response = await fetch('./api/pages');
     if (!response.ok) {
       throw new Error('Network response was not ok');
     }
 
-    const yamlText = await response.text();
-    const config = jsyaml.load(yamlText);
-
-    return Object.keys(config.Pages).length;
+    // Links are relative so the site also works when hosted under a sub-path
+    const pages = await response.json();
+    return pages.map(page => ({
+      url: page.url.replace(/^\//, ''),
+      poster: page.PosterPath.replace(/^\//, ''),
+    }));
   } catch (error) {
-    console.error('Error fetching or parsing YAML file:', error);
-    return 0;
+    console.error('Error fetching pages:', error);
+    return [];
   }
 }
 
@@ -52,7 +54,7 @@ async function init() {
   container = document.createElement('div');
   document.body.appendChild(container);
 
-  pageCount = await getYamlPagesCount();
+  pages = await getPages();
 
   camera = new THREE.PerspectiveCamera(50, window.innerWidth / window.innerHeight, 1, 10000);
   camera.position.y = 300;
@@ -70,15 +72,15 @@ async function init() {
 
   addOctahedronToScene(); addLightingToScene(); addParticlesToScene();
 
-  for (let i = 1; i <= pageCount; ++i) {
-    let map = new THREE.TextureLoader().load(`/static/models/obj${i}/object${i}.webp`);
+  for (const page of pages) {
+    let map = new THREE.TextureLoader().load(page.poster);
     let material = new THREE.SpriteMaterial({ map: map, color: 0xdfcdcd });
     let sprite = new THREE.Sprite(material);
 
     // Randomly scale the sprite
     let scale = Math.random() * 200 + 323;
     sprite.scale.set(scale, scale, 1);
-    sprite.userData = { objectNum: i };
+    sprite.userData = { url: page.url };
 
     // Random position with overlap check
     let position;
@@ -238,8 +240,7 @@ function onObjectClick(event) {
       createLightBeamEffect();
       zoomIntoObject(intersectedObject);
     } else {
-      const num = intersectedObject.userData.objectNum;
-      window.location.href = `/model${num}`;
+      window.location.href = intersectedObject.userData.url;
     }
   }
 }
@@ -301,7 +302,7 @@ async function zoomIntoObject(object) {
       camera.lookAt(object.position);
       // Optionally, fade out the lightning effect here before changing the page
       fadeLightningOut(() => {
-        window.location.href = '/story';
+        window.location.href = 'story';
       });
     }
   }
@@ -8,7 +8,6 @@ function toggleMessage(selector) {
 }
 
 document.addEventListener("DOMContentLoaded", function() {
-  const messageBubble = document.querySelector('.message-bubble');
   const icon = document.getElementById('toolbox-icon');
   const popup = document.getElementById('toolbox-popup');
 
@@ -18,15 +17,4 @@ document.addEventListener("DOMContentLoaded", function() {
       popup.style.display = 'none';
     }
   });
-
-  // Load saved message from localStorage
-  const savedMessage = localStorage.getItem('messageBubbleContent');
-  if (savedMessage) {
-    messageBubble.innerText = savedMessage;
-  }
-
-  // Save message to localStorage on input
-  messageBubble.addEventListener('input', function() {
-    localStorage.setItem('messageBubbleContent', messageBubble.innerText);
-  });
 });
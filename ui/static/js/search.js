@@ -0,0 +1,133 @@
+// Search overlay of the home and story pages, querying /api/search as the visitor types.
+// It opens with the search button or the / key and is navigated with the arrow keys.
+document.addEventListener('DOMContentLoaded', () => {
+  const overlay = document.getElementById('search-overlay');
+  const button = document.getElementById('search-button');
+  if (!overlay || !button) {
+    return;
+  }
+  const input = document.getElementById('search-input');
+  const list = document.getElementById('search-results');
+  let timer;
+  let controller;
+  let selected = -1;
+
+  function open() {
+    overlay.hidden = false;
+    input.focus();
+    input.select();
+  }
+
+  function close() {
+    overlay.hidden = true;
+    button.focus();
+  }
+
+  function select(index) {
+    const items = [...list.querySelectorAll('li a')];
+    if (items.length === 0) {
+      return;
+    }
+    selected = (index + items.length) % items.length;
+    items.forEach((item, i) => item.parentElement.classList.toggle('selected', i === selected));
+    items[selected].scrollIntoView({ block: 'nearest' });
+  }
+
+  function render(results) {
+    list.replaceChildren();
+    selected = -1;
+    if (results.length === 0) {
+      const empty = document.createElement('li');
+      empty.className = 'search-empty';
+      empty.textContent = overlay.dataset.noResults;
+      list.appendChild(empty);
+      return;
+    }
+
+    results.forEach(result => {
+      const link = document.createElement('a');
+      link.href = result.url;
+      if (result.poster) {
+        const poster = document.createElement('img');
+        poster.src = result.poster;
+        poster.alt = '';
+        poster.loading = 'lazy';
+        link.appendChild(poster);
+      }
+
+      const text = document.createElement('div');
+      const title = document.createElement('strong');
+      title.textContent = result.title;
+      const kind = document.createElement('span');
+      kind.className = 'search-kind';
+      kind.textContent = result.kind === 'model' ? overlay.dataset.modelLabel : overlay.dataset.storyLabel;
+      text.append(title, kind);
+      if (result.snippet) {
+        // The server escapes the snippet, only the <mark> around the matches is markup
+        const snippet = document.createElement('p');
+        snippet.innerHTML = result.snippet;
+        text.appendChild(snippet);
+      }
+      link.appendChild(text);
+
+      const item = document.createElement('li');
+      item.setAttribute('role', 'option');
+      item.appendChild(link);
+      list.appendChild(item);
+    });
+  }
+
+  async function search(query) {
+    if (controller) {
+      controller.abort();
+    }
+    if (!query.trim()) {
+      list.replaceChildren();
+      return;
+    }
+
+    controller = new AbortController();
+    try {
+      const response = await fetch('./api/search?q=' + encodeURIComponent(query), { signal: controller.signal });
+      if (!response.ok) {
+        throw new Error('Network response was not ok');
+      }
+      render((await response.json()).results);
+    } catch (error) {
+      if (error.name !== 'AbortError') {
+        console.error('Error searching:', error);
+      }
+    }
+  }
+
+  button.addEventListener('click', open);
+  overlay.addEventListener('click', (event) => {
+    if (event.target === overlay) {
+      close();
+    }
+  });
+
+  input.addEventListener('input', () => {
+    clearTimeout(timer);
+    timer = setTimeout(() => search(input.value), 150);
+  });
+
+  input.addEventListener('keydown', (event) => {
+    if (event.key === 'ArrowDown' || event.key === 'ArrowUp') {
+      event.preventDefault();
+      select(selected + (event.key === 'ArrowDown' ? 1 : -1));
+    } else if (event.key === 'Enter' && selected >= 0) {
+      event.preventDefault();
+      list.querySelectorAll('li a')[selected].click();
+    }
+  });
+
+  document.addEventListener('keydown', (event) => {
+    if (event.key === 'Escape' && !overlay.hidden) {
+      close();
+    } else if (event.key === '/' && overlay.hidden && !event.target.closest('input, textarea, [contenteditable]')) {
+      event.preventDefault();
+      open();
+    }
+  });
+});
@@ -0,0 +1,24 @@
+// Switch the measured specs of the info bubble between metric and imperial units,
+// remembering the choice across pages
+document.addEventListener("DOMContentLoaded", function() {
+  const values = document.querySelectorAll('.spec-value');
+  const toggle = document.querySelector('.unit-toggle');
+  if (!toggle || values.length === 0) {
+    return;
+  }
+
+  function show(system) {
+    values.forEach(value => {
+      value.textContent = value.dataset[system];
+    });
+    // The labels are translated into the locale of the page
+    toggle.textContent = system === 'metric' ? toggle.dataset.imperialLabel : toggle.dataset.metricLabel;
+    localStorage.setItem('specUnits', system);
+  }
+
+  toggle.hidden = false;
+  toggle.addEventListener('click', () => {
+    show(localStorage.getItem('specUnits') === 'imperial' ? 'metric' : 'imperial');
+  });
+  show(localStorage.getItem('specUnits') === 'imperial' ? 'imperial' : 'metric');
+});
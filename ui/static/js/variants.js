@@ -0,0 +1,42 @@
+// Switch the model between the variants of the page, keeping the choice in ?variant= so it
+// can be linked to. The page's own model is selected when the parameter is absent.
+(function() {
+  const picker = document.getElementById('variant-picker');
+  if (!picker) {
+    return;
+  }
+  const modelViewer = document.querySelector('model-viewer#transformer');
+  const variants = [...picker.querySelectorAll('.variant')];
+
+  function select(variant, updateURL) {
+    // Attributes rather than properties, as <model-viewer> may not be upgraded yet
+    modelViewer.setAttribute('poster', variant.dataset.poster);
+    modelViewer.setAttribute('src', variant.dataset.src);
+    modelViewer.setAttribute('ios-src', variant.dataset.iosSrc);
+    variants.forEach(v => v.classList.toggle('selected', v === variant));
+
+    if (updateURL) {
+      const url = new URL(window.location.href);
+      if (variant.dataset.variant) {
+        url.searchParams.set('variant', variant.dataset.variant);
+      } else {
+        url.searchParams.delete('variant');
+      }
+      history.replaceState(null, '', url);
+    }
+  }
+
+  picker.addEventListener('click', (event) => {
+    const variant = event.target.closest('.variant');
+    if (variant) {
+      select(variant, true);
+    }
+  });
+
+  // Runs before the model starts loading, so a linked variant is loaded directly
+  const requested = new URLSearchParams(window.location.search).get('variant');
+  const linked = variants.find(v => requested && v.dataset.variant === requested);
+  if (linked) {
+    select(linked, false);
+  }
+})();